@@ -1,12 +1,19 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
+	"github.com/dmltdev/flashcards/internal/auth"
 	"github.com/dmltdev/flashcards/internal/database"
 	"github.com/dmltdev/flashcards/internal/handlers"
+	"github.com/dmltdev/flashcards/internal/jobs"
+	"github.com/dmltdev/flashcards/internal/models"
+	"github.com/dmltdev/flashcards/internal/storage"
 	"github.com/joho/godotenv"
 )
 
@@ -21,27 +28,255 @@ func main() {
 	}
 	defer db.Close()
 
-	handler := handlers.NewHandler(db)
+	store, err := storage.NewLocalStorage(getEnv("MEDIA_DIR", "./data/media"))
+	if err != nil {
+		log.Fatal("Failed to initialize media storage:", err)
+	}
+
+	retentionDays, err := strconv.Atoi(getEnv("TRASH_RETENTION_DAYS", "30"))
+	if err != nil {
+		log.Fatal("Invalid TRASH_RETENTION_DAYS:", err)
+	}
+	go jobs.PurgeTrash(db, time.Duration(retentionDays)*24*time.Hour, time.Hour)
+	go jobs.CollectMedia(db, store, time.Hour, time.Hour)
+
+	secret := os.Getenv("AUTH_SECRET")
+	if len(secret) < 32 {
+		log.Fatal("AUTH_SECRET must be set to at least 32 characters")
+	}
+	accessTTL, err := strconv.Atoi(getEnv("ACCESS_TOKEN_TTL_MINUTES", "15"))
+	if err != nil {
+		log.Fatal("Invalid ACCESS_TOKEN_TTL_MINUTES:", err)
+	}
+	refreshTTL, err := strconv.Atoi(getEnv("REFRESH_TOKEN_TTL_DAYS", "30"))
+	if err != nil {
+		log.Fatal("Invalid REFRESH_TOKEN_TTL_DAYS:", err)
+	}
+	tokens := auth.NewTokenIssuer([]byte(secret), time.Duration(accessTTL)*time.Minute, time.Duration(refreshTTL)*24*time.Hour)
+
+	var oidcProvider *auth.OIDCProvider
+	if issuerURL := os.Getenv("OIDC_ISSUER_URL"); issuerURL != "" {
+		oidcProvider, err = auth.NewOIDCProvider(context.Background(), nil, issuerURL,
+			os.Getenv("OIDC_CLIENT_ID"), os.Getenv("OIDC_CLIENT_SECRET"), os.Getenv("OIDC_REDIRECT_URL"))
+		if err != nil {
+			log.Fatal("Failed to initialize OIDC provider:", err)
+		}
+	}
+
+	handler := handlers.NewHandler(db, store, tokens, oidcProvider)
 
 	mux := http.NewServeMux()
 	
+	mux.HandleFunc("POST /auth/register", handler.Register)
+	mux.HandleFunc("POST /auth/login", handler.Login)
+	mux.HandleFunc("POST /auth/refresh", handler.RefreshToken)
+	mux.HandleFunc("POST /auth/logout", handler.Logout)
+	if oidcProvider != nil {
+		mux.HandleFunc("GET /auth/oidc/login", handler.OIDCLogin)
+		mux.HandleFunc("GET /auth/oidc/callback", handler.OIDCCallback)
+	}
+	mux.HandleFunc("GET /users/me", handler.GetCurrentUser)
+	mux.HandleFunc("POST /api-keys", handler.CreateAPIKey)
+	mux.HandleFunc("GET /api-keys", handler.GetAPIKeys)
+	mux.HandleFunc("DELETE /api-keys/{id}", handler.RevokeAPIKey)
+
 	mux.HandleFunc("POST /decks", handler.CreateDeck)
 
 	mux.HandleFunc("GET /decks", handler.GetDecks)
 	mux.HandleFunc("GET /decks/{id}", handler.GetDeck)
+	mux.HandleFunc("PUT /decks/{id}", handler.UpdateDeck)
+	mux.HandleFunc("DELETE /decks/{id}", handler.DeleteDeck)
+	mux.HandleFunc("POST /decks/{id}/restore", handler.RestoreDeck)
+	mux.HandleFunc("POST /decks/{id}/rebuild", handler.RebuildDeck)
+	mux.HandleFunc("POST /decks/{id}/empty", handler.EmptyDeck)
+	mux.HandleFunc("GET /decks/{id}/stats", handler.GetDeckStats)
+	mux.HandleFunc("POST /decks/{id}/clone", handler.CloneDeck)
+	mux.HandleFunc("POST /decks/{id}/snapshots", handler.CreateDeckSnapshot)
+	mux.HandleFunc("GET /decks/{id}/snapshots", handler.GetDeckSnapshots)
+	mux.HandleFunc("GET /decks/{id}/snapshots/{version}", handler.GetDeckSnapshot)
+	mux.HandleFunc("GET /decks/{id}/snapshots/{version}/diff", handler.DiffDeckSnapshot)
+	mux.HandleFunc("POST /decks/{id}/snapshots/{version}/restore", handler.RestoreDeckSnapshot)
+	mux.HandleFunc("GET /decks/{id}/shares", handler.GetDeckShares)
+	mux.HandleFunc("PUT /decks/{id}/shares", handler.ShareDeck)
+	mux.HandleFunc("DELETE /decks/{id}/shares/{user_id}", handler.UnshareDeck)
 	
 	mux.HandleFunc("POST /decks/{id}/cards", handler.CreateCard)
+	mux.HandleFunc("GET /decks/{id}/cards", handler.GetCards)
+	mux.HandleFunc("POST /decks/{id}/cards/bulk", handler.BulkCreateCards)
 	mux.HandleFunc("GET /decks/{id}/cards/next", handler.GetNextCard)
+	mux.HandleFunc("GET /decks/{id}/duplicates", handler.GetDuplicates)
+	mux.HandleFunc("POST /decks/{id}/replace", handler.FindAndReplace)
+	mux.HandleFunc("GET /cards/{id}", handler.GetCard)
+	mux.HandleFunc("PUT /cards/{id}", handler.UpdateCard)
+	mux.HandleFunc("DELETE /cards/{id}", handler.DeleteCard)
+	mux.HandleFunc("POST /cards/{id}/restore", handler.RestoreCard)
+	mux.HandleFunc("GET /cards/{id}/history", handler.GetCardHistory)
+	mux.HandleFunc("POST /cards/{id}/revert", handler.RevertCard)
+	mux.HandleFunc("POST /cards/{id}/suspend", handler.SuspendCard)
+	mux.HandleFunc("POST /cards/{id}/unsuspend", handler.UnsuspendCard)
+	mux.HandleFunc("GET /cards/{id}/similar", handler.GetSimilarToCard)
+	mux.HandleFunc("GET /cards/similar", handler.GetSimilarCards)
+	mux.HandleFunc("POST /cards/move", handler.MoveCards)
+	mux.HandleFunc("POST /cards/copy", handler.CopyCards)
 	mux.HandleFunc("POST /cards/{id}/reviews", handler.CreateReview)
 
+	mux.HandleFunc("POST /presets", handler.CreatePreset)
+	mux.HandleFunc("GET /presets", handler.GetPresets)
+	mux.HandleFunc("GET /presets/{id}", handler.GetPreset)
+	mux.HandleFunc("PUT /presets/{id}", handler.UpdatePreset)
+	mux.HandleFunc("DELETE /presets/{id}", handler.DeletePreset)
+
+	mux.HandleFunc("GET /trash", handler.GetTrash)
+
+	mux.HandleFunc("POST /decks/{id}/publish", handler.PublishDeck)
+	mux.HandleFunc("GET /catalog", handler.GetCatalog)
+	mux.HandleFunc("GET /catalog/{id}", handler.GetPublication)
+	mux.HandleFunc("DELETE /catalog/{id}", handler.UnpublishDeck)
+	mux.HandleFunc("POST /catalog/{id}/subscribe", handler.Subscribe)
+	mux.HandleFunc("GET /subscriptions", handler.GetSubscriptions)
+	mux.HandleFunc("GET /subscriptions/{id}/updates", handler.GetSubscriptionUpdates)
+	mux.HandleFunc("POST /subscriptions/{id}/update", handler.UpdateSubscription)
+	mux.HandleFunc("DELETE /subscriptions/{id}", handler.Unsubscribe)
+
+	mux.HandleFunc("POST /classrooms", handler.CreateClassroom)
+	mux.HandleFunc("GET /classrooms", handler.GetClassrooms)
+	mux.HandleFunc("POST /classrooms/join", handler.JoinClassroom)
+	mux.HandleFunc("GET /classrooms/{id}", handler.GetClassroom)
+	mux.HandleFunc("PUT /classrooms/{id}", handler.UpdateClassroom)
+	mux.HandleFunc("DELETE /classrooms/{id}", handler.DeleteClassroom)
+	mux.HandleFunc("PUT /classrooms/{id}/members", handler.SetClassroomMember)
+	mux.HandleFunc("DELETE /classrooms/{id}/members/{user_id}", handler.RemoveClassroomMember)
+	mux.HandleFunc("POST /classrooms/{id}/assignments", handler.CreateAssignment)
+	mux.HandleFunc("GET /classrooms/{id}/assignments", handler.GetAssignments)
+	mux.HandleFunc("PUT /classrooms/{id}/assignments/{assignment_id}", handler.UpdateAssignment)
+	mux.HandleFunc("DELETE /classrooms/{id}/assignments/{assignment_id}", handler.DeleteAssignment)
+	mux.HandleFunc("GET /classrooms/{id}/progress", handler.GetClassroomProgress)
+	mux.HandleFunc("GET /classrooms/{id}/students/{user_id}/progress", handler.GetStudentProgress)
+
+	mux.HandleFunc("POST /decks/{id}/quizzes", handler.CreateQuiz)
+	mux.HandleFunc("GET /quizzes", handler.GetQuizzes)
+	mux.HandleFunc("GET /quizzes/{id}", handler.GetQuiz)
+	mux.HandleFunc("PUT /quizzes/{id}/questions/{position}", handler.AnswerQuizQuestion)
+	mux.HandleFunc("POST /quizzes/{id}/submit", handler.SubmitQuiz)
+
+	mux.HandleFunc("GET /search", handler.SearchCards)
+
+	mux.HandleFunc("GET /tags", handler.GetTags)
+	mux.HandleFunc("POST /tags/bulk", handler.BulkTagCards)
+	mux.HandleFunc("POST /cards/{id}/tags", handler.AddCardTags)
+	mux.HandleFunc("DELETE /cards/{id}/tags/{tag}", handler.RemoveCardTag)
+
+	mux.HandleFunc("POST /media", handler.UploadMedia)
+	mux.HandleFunc("GET /media/{checksum}", handler.GetMedia)
+
 	port := getEnv("SERVER_PORT", "8080")
 	log.Printf("Server starting on port %s", port)
 	
-	if err := http.ListenAndServe(":"+port, mux); err != nil {
+	if err := http.ListenAndServe(":"+port, auth.Middleware(db, tokens, mux, publicRoutes, apiKeyScopes)); err != nil {
 		log.Fatal("Server failed to start:", err)
 	}
 }
 
+// publicRoutes are served without an access token.
+var publicRoutes = []string{
+	"POST /auth/register",
+	"POST /auth/login",
+	"POST /auth/refresh",
+	"POST /auth/logout",
+	"GET /auth/oidc/login",
+	"GET /auth/oidc/callback",
+	"GET /media/{checksum}",
+	"GET /catalog",
+	"GET /catalog/{id}",
+}
+
+// apiKeyScopes names the scope an API key needs for each route it may use.
+// API keys are rejected on any other route, such as managing API keys.
+var apiKeyScopes = map[string]string{
+	"POST /decks":                                  models.ScopeDecksWrite,
+	"GET /decks":                                   models.ScopeDecksRead,
+	"GET /decks/{id}":                              models.ScopeDecksRead,
+	"PUT /decks/{id}":                              models.ScopeDecksWrite,
+	"DELETE /decks/{id}":                           models.ScopeDecksWrite,
+	"POST /decks/{id}/restore":                     models.ScopeDecksWrite,
+	"POST /decks/{id}/rebuild":                     models.ScopeDecksWrite,
+	"POST /decks/{id}/empty":                       models.ScopeDecksWrite,
+	"GET /decks/{id}/stats":                        models.ScopeReviewsRead,
+	"POST /decks/{id}/clone":                       models.ScopeDecksWrite,
+	"POST /decks/{id}/snapshots":                   models.ScopeDecksWrite,
+	"GET /decks/{id}/snapshots":                    models.ScopeDecksRead,
+	"GET /decks/{id}/snapshots/{version}":          models.ScopeDecksRead,
+	"GET /decks/{id}/snapshots/{version}/diff":     models.ScopeDecksRead,
+	"POST /decks/{id}/snapshots/{version}/restore": models.ScopeDecksWrite,
+	"GET /decks/{id}/shares":                       models.ScopeDecksRead,
+	"PUT /decks/{id}/shares":                       models.ScopeDecksWrite,
+	"DELETE /decks/{id}/shares/{user_id}":          models.ScopeDecksWrite,
+
+	"POST /decks/{id}/cards":        models.ScopeCardsWrite,
+	"GET /decks/{id}/cards":         models.ScopeCardsRead,
+	"POST /decks/{id}/cards/bulk":   models.ScopeCardsWrite,
+	"GET /decks/{id}/cards/next":    models.ScopeCardsRead,
+	"GET /decks/{id}/duplicates":    models.ScopeCardsRead,
+	"POST /decks/{id}/replace":      models.ScopeCardsWrite,
+	"GET /cards/{id}":               models.ScopeCardsRead,
+	"PUT /cards/{id}":               models.ScopeCardsWrite,
+	"DELETE /cards/{id}":            models.ScopeCardsWrite,
+	"POST /cards/{id}/restore":      models.ScopeCardsWrite,
+	"GET /cards/{id}/history":       models.ScopeCardsRead,
+	"POST /cards/{id}/revert":       models.ScopeCardsWrite,
+	"POST /cards/{id}/suspend":      models.ScopeReviewsWrite,
+	"POST /cards/{id}/unsuspend":    models.ScopeReviewsWrite,
+	"GET /cards/{id}/similar":       models.ScopeCardsRead,
+	"GET /cards/similar":            models.ScopeCardsRead,
+	"POST /cards/move":              models.ScopeCardsWrite,
+	"POST /cards/copy":              models.ScopeCardsWrite,
+	"POST /cards/{id}/reviews":      models.ScopeReviewsWrite,
+	"GET /search":                   models.ScopeCardsRead,
+	"GET /tags":                     models.ScopeCardsRead,
+	"POST /tags/bulk":               models.ScopeCardsWrite,
+	"POST /cards/{id}/tags":         models.ScopeCardsWrite,
+	"DELETE /cards/{id}/tags/{tag}": models.ScopeCardsWrite,
+
+	"POST /presets":        models.ScopePresetsWrite,
+	"GET /presets":         models.ScopePresetsRead,
+	"GET /presets/{id}":    models.ScopePresetsRead,
+	"PUT /presets/{id}":    models.ScopePresetsWrite,
+	"DELETE /presets/{id}": models.ScopePresetsWrite,
+
+	"GET /trash": models.ScopeDecksRead,
+
+	"POST /decks/{id}/publish":        models.ScopeDecksWrite,
+	"DELETE /catalog/{id}":            models.ScopeDecksWrite,
+	"POST /catalog/{id}/subscribe":    models.ScopeDecksWrite,
+	"GET /subscriptions":              models.ScopeDecksRead,
+	"GET /subscriptions/{id}/updates": models.ScopeDecksRead,
+	"POST /subscriptions/{id}/update": models.ScopeDecksWrite,
+	"DELETE /subscriptions/{id}":      models.ScopeDecksWrite,
+
+	"POST /decks/{id}/quizzes":               models.ScopeReviewsWrite,
+	"GET /quizzes":                           models.ScopeReviewsRead,
+	"GET /quizzes/{id}":                      models.ScopeReviewsRead,
+	"PUT /quizzes/{id}/questions/{position}": models.ScopeReviewsWrite,
+	"POST /quizzes/{id}/submit":              models.ScopeReviewsWrite,
+
+	"POST /media": models.ScopeMediaWrite,
+
+	"POST /classrooms":                                    models.ScopeClassroomsWrite,
+	"GET /classrooms":                                     models.ScopeClassroomsRead,
+	"POST /classrooms/join":                               models.ScopeClassroomsWrite,
+	"GET /classrooms/{id}":                                models.ScopeClassroomsRead,
+	"PUT /classrooms/{id}":                                models.ScopeClassroomsWrite,
+	"DELETE /classrooms/{id}":                             models.ScopeClassroomsWrite,
+	"PUT /classrooms/{id}/members":                        models.ScopeClassroomsWrite,
+	"DELETE /classrooms/{id}/members/{user_id}":           models.ScopeClassroomsWrite,
+	"POST /classrooms/{id}/assignments":                   models.ScopeClassroomsWrite,
+	"GET /classrooms/{id}/assignments":                    models.ScopeClassroomsRead,
+	"PUT /classrooms/{id}/assignments/{assignment_id}":    models.ScopeClassroomsWrite,
+	"DELETE /classrooms/{id}/assignments/{assignment_id}": models.ScopeClassroomsWrite,
+	"GET /classrooms/{id}/progress":                       models.ScopeClassroomsRead,
+	"GET /classrooms/{id}/students/{user_id}/progress":    models.ScopeClassroomsRead,
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 
+	"github.com/dmltdev/flashcards/internal/content"
 	"github.com/dmltdev/flashcards/internal/database"
 	"github.com/joho/godotenv"
 )
@@ -53,6 +54,29 @@ func runMigrationsUp(db *database.DB) error {
 		{Name: "001_create_decks_table", Up: createDecksTable},
 		{Name: "002_create_cards_table", Up: createCardsTable},
 		{Name: "003_create_reviews_table", Up: createReviewsTable},
+		{Name: "004_add_card_content_format", Up: addCardContentFormat},
+		{Name: "005_create_media_tables", Up: createMediaTables},
+		{Name: "006_create_tags_tables", Up: createTagsTables},
+		{Name: "007_add_card_search_vector", Up: addCardSearchVector},
+		{Name: "008_add_card_front_normalized", Up: addCardFrontNormalized},
+		{Name: "009_add_card_front_trigram_index", Up: addCardFrontTrigramIndex},
+		{Name: "010_create_card_revisions_table", Up: createCardRevisionsTable},
+		{Name: "011_add_soft_delete", Up: addSoftDelete},
+		{Name: "012_add_deck_parent", Up: addDeckParent},
+		{Name: "013_create_presets_table", Up: createPresetsTable},
+		{Name: "014_add_filtered_decks", Up: addFilteredDecks},
+		{Name: "015_create_deck_snapshots_table", Up: createDeckSnapshotsTable},
+		{Name: "016_create_users_table", Up: createUsersTable},
+		{Name: "017_add_user_credentials", Up: addUserCredentials},
+		{Name: "018_create_api_keys_table", Up: createAPIKeysTable},
+		{Name: "019_create_oidc_tables", Up: createOIDCTables},
+		{Name: "020_add_deck_sharing", Up: addDeckSharing},
+		{Name: "021_create_catalog_tables", Up: createCatalogTables},
+		{Name: "022_create_classroom_tables", Up: createClassroomTables},
+		{Name: "023_create_quiz_tables", Up: createQuizTables},
+		{Name: "024_add_study_tracking", Up: addStudyTracking},
+		{Name: "025_keep_shared_cards_home", Up: keepSharedCardsHome},
+		{Name: "026_create_card_suspensions_table", Up: createCardSuspensionsTable},
 	}
 
 	for _, migration := range migrations {
@@ -67,10 +91,39 @@ func runMigrationsUp(db *database.DB) error {
 func runMigrationsDown(db *database.DB) error {
 	// Drop tables in reverse order
 	queries := []string{
+		"DROP TABLE IF EXISTS card_suspensions CASCADE;",
+		"DROP TABLE IF EXISTS quiz_questions CASCADE;",
+		"DROP TABLE IF EXISTS quizzes CASCADE;",
+		"DROP TABLE IF EXISTS classroom_assignments CASCADE;",
+		"DROP TABLE IF EXISTS classroom_members CASCADE;",
+		"DROP TABLE IF EXISTS classrooms CASCADE;",
+		"DROP TABLE IF EXISTS subscriptions CASCADE;",
+		"DROP TABLE IF EXISTS publication_versions CASCADE;",
+		"DROP TABLE IF EXISTS publications CASCADE;",
+		"DROP TABLE IF EXISTS deck_shares CASCADE;",
+		"DROP TABLE IF EXISTS oidc_logins CASCADE;",
+		"DROP TABLE IF EXISTS user_identities CASCADE;",
+		"DROP TABLE IF EXISTS api_keys CASCADE;",
+		"DROP TABLE IF EXISTS refresh_tokens CASCADE;",
+		"DROP TABLE IF EXISTS deck_snapshots CASCADE;",
+		"DROP TABLE IF EXISTS card_revisions CASCADE;",
+		"DROP TABLE IF EXISTS card_tags CASCADE;",
+		"DROP TABLE IF EXISTS tags CASCADE;",
+		"DROP TABLE IF EXISTS card_media CASCADE;",
+		"DROP TABLE IF EXISTS media CASCADE;",
 		"DROP TABLE IF EXISTS reviews CASCADE;",
 		"DROP TABLE IF EXISTS cards CASCADE;",
 		"DROP TABLE IF EXISTS decks CASCADE;",
+		"DROP TABLE IF EXISTS presets CASCADE;",
+		"DROP TABLE IF EXISTS users CASCADE;",
 		"DROP TABLE IF EXISTS migrations CASCADE;",
+		"DROP FUNCTION IF EXISTS deck_is_shared(INTEGER);",
+		"DROP FUNCTION IF EXISTS user_decks(INTEGER, VARCHAR);",
+		"DROP FUNCTION IF EXISTS deck_role(INTEGER, INTEGER);",
+		"DROP FUNCTION IF EXISTS deck_role_rank(VARCHAR);",
+		"DROP FUNCTION IF EXISTS card_tags_search_vector_trigger();",
+		"DROP FUNCTION IF EXISTS cards_search_vector_trigger();",
+		"DROP FUNCTION IF EXISTS card_search_vector(INTEGER, TEXT, TEXT);",
 	}
 
 	for _, query := range queries {
@@ -187,4 +240,717 @@ func createReviewsTable(db *database.DB) error {
 
 	_, err := db.Exec(query)
 	return err
-}
\ No newline at end of file
+}
+
+func addCardContentFormat(db *database.DB) error {
+	query := `
+		ALTER TABLE cards
+			ADD COLUMN format VARCHAR(16) NOT NULL DEFAULT 'plain'
+				CHECK (format IN ('plain', 'markdown', 'html')),
+			ADD COLUMN front_html TEXT NOT NULL DEFAULT '',
+			ADD COLUMN back_html TEXT NOT NULL DEFAULT '';`
+
+	if _, err := db.Exec(query); err != nil {
+		return err
+	}
+
+	// Existing cards are plain text, render them so clients can rely on the HTML fields
+	var cards []struct {
+		ID    int    `db:"id"`
+		Front string `db:"front"`
+		Back  string `db:"back"`
+	}
+	if err := db.Select(&cards, "SELECT id, front, back FROM cards"); err != nil {
+		return err
+	}
+
+	for _, card := range cards {
+		front, err := content.Render(content.FormatPlain, card.Front)
+		if err != nil {
+			return err
+		}
+		back, err := content.Render(content.FormatPlain, card.Back)
+		if err != nil {
+			return err
+		}
+
+		_, err = db.Exec("UPDATE cards SET front_html = $1, back_html = $2 WHERE id = $3", front, back, card.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func createMediaTables(db *database.DB) error {
+	query := `
+		CREATE TABLE media (
+			id SERIAL PRIMARY KEY,
+			checksum CHAR(64) NOT NULL UNIQUE,
+			mime_type VARCHAR(255) NOT NULL,
+			size BIGINT NOT NULL,
+			filename VARCHAR(255) NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE card_media (
+			card_id INTEGER NOT NULL REFERENCES cards(id) ON DELETE CASCADE,
+			media_id INTEGER NOT NULL REFERENCES media(id) ON DELETE CASCADE,
+			PRIMARY KEY (card_id, media_id)
+		);
+
+		CREATE INDEX idx_card_media_media_id ON card_media(media_id);
+
+		CREATE TRIGGER update_media_updated_at
+			BEFORE UPDATE ON media
+			FOR EACH ROW
+			EXECUTE FUNCTION update_updated_at_column();`
+
+	_, err := db.Exec(query)
+	return err
+}
+
+func createTagsTables(db *database.DB) error {
+	query := `
+		CREATE TABLE tags (
+			id SERIAL PRIMARY KEY,
+			name VARCHAR(64) NOT NULL UNIQUE,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE card_tags (
+			card_id INTEGER NOT NULL REFERENCES cards(id) ON DELETE CASCADE,
+			tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+			PRIMARY KEY (card_id, tag_id)
+		);
+
+		CREATE INDEX idx_card_tags_tag_id ON card_tags(tag_id);
+
+		CREATE TRIGGER update_tags_updated_at
+			BEFORE UPDATE ON tags
+			FOR EACH ROW
+			EXECUTE FUNCTION update_updated_at_column();`
+
+	_, err := db.Exec(query)
+	return err
+}
+
+func addCardSearchVector(db *database.DB) error {
+	// The search vector covers front, back and tags. Tags live in another
+	// table, so changes to card_tags refresh the vector of the card as well.
+	query := `
+		ALTER TABLE cards ADD COLUMN search_vector TSVECTOR;
+
+		CREATE FUNCTION card_search_vector(p_card_id INTEGER, p_front TEXT, p_back TEXT)
+		RETURNS TSVECTOR AS $$
+			SELECT
+				setweight(to_tsvector('simple', COALESCE(p_front, '')), 'A') ||
+				setweight(to_tsvector('simple', COALESCE(p_back, '')), 'B') ||
+				setweight(to_tsvector('simple', COALESCE((
+					SELECT string_agg(t.name, ' ')
+					FROM card_tags ct JOIN tags t ON t.id = ct.tag_id
+					WHERE ct.card_id = p_card_id
+				), '')), 'C')
+		$$ LANGUAGE sql STABLE;
+
+		CREATE FUNCTION cards_search_vector_trigger()
+		RETURNS TRIGGER AS $$
+		BEGIN
+			NEW.search_vector = card_search_vector(NEW.id, NEW.front, NEW.back);
+			RETURN NEW;
+		END;
+		$$ LANGUAGE plpgsql;
+
+		CREATE FUNCTION card_tags_search_vector_trigger()
+		RETURNS TRIGGER AS $$
+		DECLARE
+			v_card_id INTEGER;
+		BEGIN
+			IF TG_OP = 'DELETE' THEN
+				v_card_id = OLD.card_id;
+			ELSE
+				v_card_id = NEW.card_id;
+			END IF;
+
+			UPDATE cards
+			SET search_vector = card_search_vector(id, front, back)
+			WHERE id = v_card_id;
+
+			RETURN NULL;
+		END;
+		$$ LANGUAGE plpgsql;
+
+		CREATE TRIGGER update_cards_search_vector
+			BEFORE INSERT OR UPDATE OF front, back ON cards
+			FOR EACH ROW
+			EXECUTE FUNCTION cards_search_vector_trigger();
+
+		CREATE TRIGGER update_card_tags_search_vector
+			AFTER INSERT OR DELETE ON card_tags
+			FOR EACH ROW
+			EXECUTE FUNCTION card_tags_search_vector_trigger();
+
+		UPDATE cards SET search_vector = card_search_vector(id, front, back);
+
+		CREATE INDEX idx_cards_search_vector ON cards USING GIN (search_vector);`
+
+	_, err := db.Exec(query)
+	return err
+}
+
+func addCardFrontNormalized(db *database.DB) error {
+	query := `ALTER TABLE cards ADD COLUMN front_normalized TEXT NOT NULL DEFAULT '';`
+
+	if _, err := db.Exec(query); err != nil {
+		return err
+	}
+
+	var cards []struct {
+		ID     int    `db:"id"`
+		Front  string `db:"front"`
+		Format string `db:"format"`
+	}
+	if err := db.Select(&cards, "SELECT id, front, format FROM cards"); err != nil {
+		return err
+	}
+
+	for _, card := range cards {
+		normalized, err := content.Normalize(card.Format, card.Front)
+		if err != nil {
+			return err
+		}
+
+		_, err = db.Exec("UPDATE cards SET front_normalized = $1 WHERE id = $2", normalized, card.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := db.Exec("CREATE INDEX idx_cards_deck_id_front_normalized ON cards(deck_id, front_normalized);")
+	return err
+}
+
+func addCardFrontTrigramIndex(db *database.DB) error {
+	query := `
+		CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+		CREATE INDEX idx_cards_front_normalized_trgm ON cards USING GIN (front_normalized gin_trgm_ops);`
+
+	_, err := db.Exec(query)
+	return err
+}
+
+func createCardRevisionsTable(db *database.DB) error {
+	query := `
+		ALTER TABLE cards ADD COLUMN updated_by VARCHAR(255) NOT NULL DEFAULT '';
+
+		CREATE TABLE card_revisions (
+			id SERIAL PRIMARY KEY,
+			card_id INTEGER NOT NULL REFERENCES cards(id) ON DELETE CASCADE,
+			front TEXT NOT NULL,
+			back TEXT NOT NULL,
+			format VARCHAR(16) NOT NULL,
+			author VARCHAR(255) NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL,
+			archived_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE INDEX idx_card_revisions_card_id ON card_revisions(card_id);`
+
+	_, err := db.Exec(query)
+	return err
+}
+
+func addSoftDelete(db *database.DB) error {
+	query := `
+		ALTER TABLE decks ADD COLUMN deleted_at TIMESTAMP;
+		ALTER TABLE cards ADD COLUMN deleted_at TIMESTAMP;
+
+		CREATE INDEX idx_decks_deleted_at ON decks(deleted_at) WHERE deleted_at IS NOT NULL;
+		CREATE INDEX idx_cards_deleted_at ON cards(deleted_at) WHERE deleted_at IS NOT NULL;`
+
+	_, err := db.Exec(query)
+	return err
+}
+
+func addDeckParent(db *database.DB) error {
+	query := `
+		ALTER TABLE decks ADD COLUMN parent_id INTEGER REFERENCES decks(id) ON DELETE CASCADE;
+
+		CREATE INDEX idx_decks_parent_id ON decks(parent_id);`
+
+	_, err := db.Exec(query)
+	return err
+}
+
+func createPresetsTable(db *database.DB) error {
+	query := `
+		CREATE TABLE presets (
+			id SERIAL PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			options JSONB NOT NULL DEFAULT '{}',
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TRIGGER update_presets_updated_at
+			BEFORE UPDATE ON presets
+			FOR EACH ROW
+			EXECUTE FUNCTION update_updated_at_column();
+
+		ALTER TABLE decks
+			ADD COLUMN preset_id INTEGER REFERENCES presets(id) ON DELETE SET NULL,
+			ADD COLUMN options JSONB NOT NULL DEFAULT '{}';
+
+		CREATE INDEX idx_decks_preset_id ON decks(preset_id);`
+
+	_, err := db.Exec(query)
+	return err
+}
+func addFilteredDecks(db *database.DB) error {
+	query := `
+		ALTER TABLE decks
+			ADD COLUMN kind VARCHAR(16) NOT NULL DEFAULT 'normal' CHECK (kind IN ('normal', 'filtered')),
+			ADD COLUMN filter JSONB;
+
+		ALTER TABLE cards ADD COLUMN original_deck_id INTEGER REFERENCES decks(id) ON DELETE SET NULL;
+
+		CREATE INDEX idx_cards_original_deck_id ON cards(original_deck_id) WHERE original_deck_id IS NOT NULL;`
+
+	_, err := db.Exec(query)
+	return err
+}
+
+func createDeckSnapshotsTable(db *database.DB) error {
+	query := `
+		CREATE TABLE deck_snapshots (
+			id SERIAL PRIMARY KEY,
+			deck_id INTEGER NOT NULL REFERENCES decks(id) ON DELETE CASCADE,
+			version INTEGER NOT NULL,
+			label VARCHAR(255) NOT NULL DEFAULT '',
+			card_count INTEGER NOT NULL,
+			author VARCHAR(255) NOT NULL DEFAULT '',
+			content JSONB NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (deck_id, version)
+		);`
+
+	_, err := db.Exec(query)
+	return err
+}
+
+func createUsersTable(db *database.DB) error {
+	query := `
+		CREATE TABLE users (
+			id SERIAL PRIMARY KEY,
+			email VARCHAR(255) NOT NULL UNIQUE,
+			name VARCHAR(255) NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TRIGGER update_users_updated_at
+			BEFORE UPDATE ON users
+			FOR EACH ROW
+			EXECUTE FUNCTION update_updated_at_column();
+
+		ALTER TABLE decks ADD COLUMN user_id INTEGER REFERENCES users(id) ON DELETE CASCADE;
+		ALTER TABLE presets ADD COLUMN user_id INTEGER REFERENCES users(id) ON DELETE CASCADE;
+
+		-- Data created before accounts existed goes to a single owner
+		INSERT INTO users (email, name)
+		SELECT 'owner@localhost', 'Owner'
+		WHERE EXISTS (SELECT 1 FROM decks) OR EXISTS (SELECT 1 FROM presets);
+
+		UPDATE decks SET user_id = (SELECT id FROM users WHERE email = 'owner@localhost');
+		UPDATE presets SET user_id = (SELECT id FROM users WHERE email = 'owner@localhost');
+
+		ALTER TABLE decks ALTER COLUMN user_id SET NOT NULL;
+		ALTER TABLE presets ALTER COLUMN user_id SET NOT NULL;
+
+		CREATE INDEX idx_decks_user_id ON decks(user_id);
+		CREATE INDEX idx_presets_user_id ON presets(user_id);`
+
+	_, err := db.Exec(query)
+	return err
+}
+
+func addUserCredentials(db *database.DB) error {
+	query := `
+		-- Accounts created before passwords existed cannot log in until one is set
+		ALTER TABLE users ADD COLUMN password_hash VARCHAR(255);
+
+		CREATE TABLE refresh_tokens (
+			id SERIAL PRIMARY KEY,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			token_hash CHAR(64) NOT NULL UNIQUE,
+			expires_at TIMESTAMP NOT NULL,
+			revoked_at TIMESTAMP,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE INDEX idx_refresh_tokens_user_id ON refresh_tokens(user_id);`
+
+	_, err := db.Exec(query)
+	return err
+}
+
+func createAPIKeysTable(db *database.DB) error {
+	query := `
+		CREATE TABLE api_keys (
+			id SERIAL PRIMARY KEY,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			name VARCHAR(255) NOT NULL,
+			prefix VARCHAR(16) NOT NULL,
+			key_hash CHAR(64) NOT NULL UNIQUE,
+			scopes TEXT[] NOT NULL,
+			expires_at TIMESTAMP,
+			last_used_at TIMESTAMP,
+			revoked_at TIMESTAMP,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE INDEX idx_api_keys_user_id ON api_keys(user_id);`
+
+	_, err := db.Exec(query)
+	return err
+}
+
+func createOIDCTables(db *database.DB) error {
+	query := `
+		CREATE TABLE user_identities (
+			id SERIAL PRIMARY KEY,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			issuer VARCHAR(255) NOT NULL,
+			subject VARCHAR(255) NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(issuer, subject)
+		);
+
+		CREATE INDEX idx_user_identities_user_id ON user_identities(user_id);
+
+		CREATE TABLE oidc_logins (
+			state_hash CHAR(64) PRIMARY KEY,
+			nonce VARCHAR(64) NOT NULL,
+			verifier VARCHAR(128) NOT NULL,
+			expires_at TIMESTAMP NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);`
+
+	_, err := db.Exec(query)
+	return err
+}
+
+func addDeckSharing(db *database.DB) error {
+	query := `
+		CREATE TABLE deck_shares (
+			id SERIAL PRIMARY KEY,
+			deck_id INTEGER NOT NULL REFERENCES decks(id) ON DELETE CASCADE,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			role VARCHAR(16) NOT NULL CHECK (role IN ('viewer', 'editor', 'owner')),
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(deck_id, user_id)
+		);
+
+		CREATE INDEX idx_deck_shares_user_id ON deck_shares(user_id);
+
+		CREATE TRIGGER update_deck_shares_updated_at
+			BEFORE UPDATE ON deck_shares
+			FOR EACH ROW
+			EXECUTE FUNCTION update_updated_at_column();
+
+		CREATE FUNCTION deck_role_rank(role VARCHAR) RETURNS INTEGER AS $$
+			SELECT CASE role WHEN 'owner' THEN 3 WHEN 'editor' THEN 2 WHEN 'viewer' THEN 1 END
+		$$ LANGUAGE SQL IMMUTABLE;
+
+		-- The role of a user on a deck: owner for the user owning it, or else
+		-- the highest role shared with the user on the deck or an ancestor
+		CREATE FUNCTION deck_role(p_deck_id INTEGER, p_user_id INTEGER) RETURNS VARCHAR AS $$
+			WITH RECURSIVE ancestors AS (
+				SELECT id, parent_id, user_id FROM decks WHERE id = p_deck_id
+				UNION ALL
+				SELECT d.id, d.parent_id, d.user_id FROM decks d JOIN ancestors a ON d.id = a.parent_id
+			)
+			SELECT role FROM (
+				SELECT 'owner'::VARCHAR AS role FROM decks WHERE id = p_deck_id AND user_id = p_user_id
+				UNION ALL
+				SELECT s.role FROM deck_shares s JOIN ancestors a ON a.id = s.deck_id WHERE s.user_id = p_user_id
+			) roles
+			ORDER BY deck_role_rank(role) DESC
+			LIMIT 1
+		$$ LANGUAGE SQL STABLE;
+
+		-- The IDs of the decks a user owns or holds at least p_min_role on,
+		-- through a share of the deck or an ancestor
+		CREATE FUNCTION user_decks(p_user_id INTEGER, p_min_role VARCHAR) RETURNS SETOF INTEGER AS $$
+			WITH RECURSIVE shared AS (
+				SELECT deck_id AS id FROM deck_shares
+				WHERE user_id = p_user_id AND deck_role_rank(role) >= deck_role_rank(p_min_role)
+				UNION
+				SELECT d.id FROM decks d JOIN shared s ON d.parent_id = s.id
+			)
+			SELECT id FROM decks WHERE user_id = p_user_id
+			UNION
+			SELECT id FROM shared
+		$$ LANGUAGE SQL STABLE;
+
+		-- Every collaborator studies shared cards on their own schedule
+		ALTER TABLE reviews ADD COLUMN user_id INTEGER REFERENCES users(id) ON DELETE CASCADE;
+
+		UPDATE reviews r SET user_id = d.user_id
+		FROM cards c JOIN decks d ON d.id = COALESCE(c.original_deck_id, c.deck_id)
+		WHERE c.id = r.card_id;
+
+		ALTER TABLE reviews ALTER COLUMN user_id SET NOT NULL;
+
+		CREATE INDEX idx_reviews_user_card ON reviews(user_id, card_id, reviewed_at DESC);`
+
+	_, err := db.Exec(query)
+	return err
+}
+
+func createCatalogTables(db *database.DB) error {
+	query := `
+		CREATE TABLE publications (
+			id SERIAL PRIMARY KEY,
+			deck_id INTEGER UNIQUE REFERENCES decks(id) ON DELETE SET NULL,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			name VARCHAR(255) NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			version INTEGER NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TRIGGER update_publications_updated_at
+			BEFORE UPDATE ON publications
+			FOR EACH ROW
+			EXECUTE FUNCTION update_updated_at_column();
+
+		CREATE TABLE publication_versions (
+			id SERIAL PRIMARY KEY,
+			publication_id INTEGER NOT NULL REFERENCES publications(id) ON DELETE CASCADE,
+			version INTEGER NOT NULL,
+			notes TEXT NOT NULL DEFAULT '',
+			card_count INTEGER NOT NULL,
+			content JSONB NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (publication_id, version)
+		);
+
+		CREATE TABLE subscriptions (
+			id SERIAL PRIMARY KEY,
+			publication_id INTEGER NOT NULL REFERENCES publications(id) ON DELETE CASCADE,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			deck_id INTEGER NOT NULL UNIQUE REFERENCES decks(id) ON DELETE CASCADE,
+			version INTEGER NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (publication_id, user_id)
+		);
+
+		CREATE TRIGGER update_subscriptions_updated_at
+			BEFORE UPDATE ON subscriptions
+			FOR EACH ROW
+			EXECUTE FUNCTION update_updated_at_column();
+
+		-- The published card a subscribed copy was made from
+		ALTER TABLE cards ADD COLUMN source_card_id INTEGER;
+
+		CREATE INDEX idx_cards_source_card_id ON cards(source_card_id) WHERE source_card_id IS NOT NULL;`
+
+	_, err := db.Exec(query)
+	return err
+}
+
+func createClassroomTables(db *database.DB) error {
+	query := `
+		CREATE TABLE classrooms (
+			id SERIAL PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			join_code VARCHAR(16) NOT NULL UNIQUE,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TRIGGER update_classrooms_updated_at
+			BEFORE UPDATE ON classrooms
+			FOR EACH ROW
+			EXECUTE FUNCTION update_updated_at_column();
+
+		CREATE TABLE classroom_members (
+			classroom_id INTEGER NOT NULL REFERENCES classrooms(id) ON DELETE CASCADE,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			role VARCHAR(16) NOT NULL CHECK (role IN ('teacher', 'student')),
+			joined_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (classroom_id, user_id)
+		);
+
+		CREATE INDEX idx_classroom_members_user_id ON classroom_members(user_id);
+
+		CREATE TABLE classroom_assignments (
+			id SERIAL PRIMARY KEY,
+			classroom_id INTEGER NOT NULL REFERENCES classrooms(id) ON DELETE CASCADE,
+			deck_id INTEGER NOT NULL REFERENCES decks(id) ON DELETE CASCADE,
+			due_at TIMESTAMP,
+			target_mastery FLOAT8 CHECK (target_mastery > 0 AND target_mastery <= 1),
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (classroom_id, deck_id)
+		);
+
+		CREATE INDEX idx_classroom_assignments_deck_id ON classroom_assignments(deck_id);
+
+		CREATE TRIGGER update_classroom_assignments_updated_at
+			BEFORE UPDATE ON classroom_assignments
+			FOR EACH ROW
+			EXECUTE FUNCTION update_updated_at_column();
+
+		-- Members of a classroom can view the decks assigned to it
+		CREATE OR REPLACE FUNCTION deck_role(p_deck_id INTEGER, p_user_id INTEGER) RETURNS VARCHAR AS $$
+			WITH RECURSIVE ancestors AS (
+				SELECT id, parent_id, user_id FROM decks WHERE id = p_deck_id
+				UNION ALL
+				SELECT d.id, d.parent_id, d.user_id FROM decks d JOIN ancestors a ON d.id = a.parent_id
+			)
+			SELECT role FROM (
+				SELECT 'owner'::VARCHAR AS role FROM decks WHERE id = p_deck_id AND user_id = p_user_id
+				UNION ALL
+				SELECT s.role FROM deck_shares s JOIN ancestors a ON a.id = s.deck_id WHERE s.user_id = p_user_id
+				UNION ALL
+				SELECT 'viewer'::VARCHAR FROM classroom_assignments ca
+				JOIN ancestors a ON a.id = ca.deck_id
+				JOIN classroom_members m ON m.classroom_id = ca.classroom_id
+				WHERE m.user_id = p_user_id
+			) roles
+			ORDER BY deck_role_rank(role) DESC
+			LIMIT 1
+		$$ LANGUAGE SQL STABLE;
+
+		CREATE OR REPLACE FUNCTION user_decks(p_user_id INTEGER, p_min_role VARCHAR) RETURNS SETOF INTEGER AS $$
+			WITH RECURSIVE shared AS (
+				SELECT id FROM (
+					SELECT deck_id AS id FROM deck_shares
+					WHERE user_id = p_user_id AND deck_role_rank(role) >= deck_role_rank(p_min_role)
+					UNION
+					SELECT ca.deck_id FROM classroom_assignments ca
+					JOIN classroom_members m ON m.classroom_id = ca.classroom_id
+					WHERE m.user_id = p_user_id AND p_min_role = 'viewer'
+				) granted
+				UNION
+				SELECT d.id FROM decks d JOIN shared s ON d.parent_id = s.id
+			)
+			SELECT id FROM decks WHERE user_id = p_user_id
+			UNION
+			SELECT id FROM shared
+		$$ LANGUAGE SQL STABLE;`
+
+	_, err := db.Exec(query)
+	return err
+}
+
+func createQuizTables(db *database.DB) error {
+	query := `
+		CREATE TABLE quizzes (
+			id SERIAL PRIMARY KEY,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			deck_id INTEGER NOT NULL REFERENCES decks(id) ON DELETE CASCADE,
+			mode VARCHAR(16) NOT NULL CHECK (mode IN ('typed', 'multiple_choice')),
+			status VARCHAR(16) NOT NULL DEFAULT 'in_progress' CHECK (status IN ('in_progress', 'completed', 'timed_out')),
+			question_count INTEGER NOT NULL,
+			correct INTEGER,
+			score FLOAT8,
+			time_limit_seconds INTEGER,
+			expires_at TIMESTAMP,
+			submitted_at TIMESTAMP,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE INDEX idx_quizzes_user_id ON quizzes(user_id);
+
+		-- Questions keep the content of their card as it was when the quiz
+		-- was generated
+		CREATE TABLE quiz_questions (
+			quiz_id INTEGER NOT NULL REFERENCES quizzes(id) ON DELETE CASCADE,
+			position INTEGER NOT NULL,
+			card_id INTEGER REFERENCES cards(id) ON DELETE SET NULL,
+			front_html TEXT NOT NULL,
+			answer TEXT NOT NULL,
+			answer_format VARCHAR(16) NOT NULL,
+			answer_html TEXT NOT NULL,
+			choices TEXT[],
+			correct_choice INTEGER,
+			response TEXT,
+			choice INTEGER,
+			correct BOOLEAN,
+			answered_at TIMESTAMP,
+			PRIMARY KEY (quiz_id, position)
+		);`
+
+	_, err := db.Exec(query)
+	return err
+}
+
+// addStudyTracking records how long each review took and lets cards be
+// suspended, which keeps them out of study without deleting them.
+func addStudyTracking(db *database.DB) error {
+	query := `
+		ALTER TABLE reviews ADD COLUMN duration_ms INTEGER;
+		ALTER TABLE cards ADD COLUMN suspended BOOLEAN NOT NULL DEFAULT FALSE;`
+
+	_, err := db.Exec(query)
+	return err
+}
+
+// keepSharedCardsHome returns the cards filtered decks borrowed from shared
+// decks. Other users cannot see the filtered decks of the owner, so the
+// cards would disappear for them.
+func keepSharedCardsHome(db *database.DB) error {
+	query := `
+		-- Whether a deck or one of its ancestors is shared with a user or
+		-- assigned to a classroom
+		CREATE FUNCTION deck_is_shared(p_deck_id INTEGER) RETURNS BOOLEAN AS $$
+			WITH RECURSIVE ancestors AS (
+				SELECT id, parent_id FROM decks WHERE id = p_deck_id
+				UNION ALL
+				SELECT d.id, d.parent_id FROM decks d JOIN ancestors a ON d.id = a.parent_id
+			)
+			SELECT EXISTS (SELECT 1 FROM deck_shares s JOIN ancestors a ON a.id = s.deck_id)
+				OR EXISTS (SELECT 1 FROM classroom_assignments ca JOIN ancestors a ON a.id = ca.deck_id)
+		$$ LANGUAGE SQL STABLE;
+
+		UPDATE cards
+		SET deck_id = original_deck_id, original_deck_id = NULL
+		WHERE original_deck_id IS NOT NULL AND deck_is_shared(original_deck_id);`
+
+	_, err := db.Exec(query)
+	return err
+}
+
+// createCardSuspensionsTable keeps suspensions per user, like reviews, so a
+// collaborator suspending a shared card does not hide it from the others.
+// Cards suspended so far stay suspended for the owner of their home deck.
+func createCardSuspensionsTable(db *database.DB) error {
+	query := `
+		CREATE TABLE card_suspensions (
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			card_id INTEGER NOT NULL REFERENCES cards(id) ON DELETE CASCADE,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (user_id, card_id)
+		);
+
+		INSERT INTO card_suspensions (user_id, card_id)
+		SELECT d.user_id, c.id
+		FROM cards c JOIN decks d ON d.id = COALESCE(c.original_deck_id, c.deck_id)
+		WHERE c.suspended;
+
+		ALTER TABLE cards DROP COLUMN suspended;`
+
+	_, err := db.Exec(query)
+	return err
+}
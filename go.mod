@@ -3,7 +3,19 @@ module github.com/dmltdev/flashcards
 go 1.24.1
 
 require (
+	github.com/coreos/go-oidc/v3 v3.16.0
 	github.com/jmoiron/sqlx v1.4.0
 	github.com/joho/godotenv v1.5.1
 	github.com/lib/pq v1.10.9
+	github.com/microcosm-cc/bluemonday v1.0.27
+	github.com/yuin/goldmark v1.8.6
+	golang.org/x/crypto v0.45.0
+	golang.org/x/oauth2 v0.34.0
+)
+
+require (
+	github.com/aymerick/douceur v0.2.0 // indirect
+	github.com/go-jose/go-jose/v4 v4.1.3 // indirect
+	github.com/gorilla/css v1.0.1 // indirect
+	golang.org/x/net v0.47.0 // indirect
 )
@@ -0,0 +1,28 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// apiKeyPrefix marks API keys so they can be told apart from access tokens
+// in the Authorization header and spotted by secret scanners.
+const apiKeyPrefix = "fck_"
+
+// NewAPIKey returns a random API key, the prefix shown to identify it once
+// the key itself is no longer available, and the hash to store.
+func NewAPIKey() (key, prefix, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+
+	key = apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return key, key[:len(apiKeyPrefix)+8], HashToken(key), nil
+}
+
+func isAPIKey(token string) bool {
+	return strings.HasPrefix(token, apiKeyPrefix)
+}
@@ -0,0 +1,21 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/dmltdev/flashcards/internal/models"
+)
+
+type contextKey struct{}
+
+// WithUser returns a copy of the context carrying the authenticated user.
+func WithUser(ctx context.Context, user *models.User) context.Context {
+	return context.WithValue(ctx, contextKey{}, user)
+}
+
+// UserFromContext returns the authenticated user of a request context, or
+// nil if the request is not authenticated.
+func UserFromContext(ctx context.Context) *models.User {
+	user, _ := ctx.Value(contextKey{}).(*models.User)
+	return user
+}
@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/dmltdev/flashcards/internal/database"
+	"github.com/dmltdev/flashcards/internal/logger"
+)
+
+var log = logger.New("auth")
+
+// Middleware authenticates every request served by mux, except those
+// matching one of the public route patterns, and stores the user in the
+// request context. Clients authenticate with an access token or an API key
+// in the Authorization header. An API key is only accepted on the routes
+// listed in scopes, and only if it was granted the scope listed there.
+func Middleware(db *database.DB, tokens *TokenIssuer, mux *http.ServeMux, public []string, scopes map[string]string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, pattern := mux.Handler(r)
+		if slices.Contains(public, pattern) {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			unauthorized(w)
+			return
+		}
+
+		var id int
+		if isAPIKey(token) {
+			key, err := db.UseAPIKey(HashToken(token))
+			if err != nil {
+				log.Error("Failed to check API key", err)
+				http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
+				return
+			}
+			if key == nil {
+				unauthorized(w)
+				return
+			}
+
+			scope, ok := scopes[pattern]
+			if !ok {
+				http.Error(w, "API keys cannot access this endpoint", http.StatusForbidden)
+				return
+			}
+			if !slices.Contains(key.Scopes, scope) {
+				http.Error(w, "API key is missing the "+scope+" scope", http.StatusForbidden)
+				return
+			}
+			id = key.UserID
+		} else {
+			var err error
+			if id, err = tokens.ParseAccessToken(token); err != nil {
+				unauthorized(w)
+				return
+			}
+		}
+
+		user, err := db.GetUser(id)
+		if err != nil {
+			log.Warn("Unknown user", "user_id", id)
+			unauthorized(w)
+			return
+		}
+
+		mux.ServeHTTP(w, r.WithContext(WithUser(r.Context(), user)))
+	})
+}
+
+func unauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="flashcards"`)
+	http.Error(w, "Unauthorized", http.StatusUnauthorized)
+}
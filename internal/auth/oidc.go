@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/dmltdev/flashcards/internal/models"
+	"golang.org/x/oauth2"
+)
+
+// OIDCProvider signs users in with an OpenID Connect provider using the
+// authorization code flow with PKCE.
+type OIDCProvider struct {
+	config   oauth2.Config
+	verifier *oidc.IDTokenVerifier
+	client   *http.Client
+}
+
+// NewOIDCProvider discovers the endpoints and signing keys of the provider at
+// issuerURL. Requests to the provider go through client, or
+// http.DefaultClient if it is nil.
+func NewOIDCProvider(ctx context.Context, client *http.Client, issuerURL, clientID, clientSecret, redirectURL string) (*OIDCProvider, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	provider, err := oidc.NewProvider(oidc.ClientContext(ctx, client), issuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover OIDC provider: %w", err)
+	}
+
+	return &OIDCProvider{
+		config: oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			RedirectURL:  redirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       []string{oidc.ScopeOpenID, "email", "profile"},
+		},
+		verifier: provider.Verifier(&oidc.Config{ClientID: clientID}),
+		client:   client,
+	}, nil
+}
+
+// OIDCLogin holds the values binding a callback to the login that started
+// it. They stay on the server, except for State which the browser carries.
+type OIDCLogin struct {
+	State    string
+	Nonce    string
+	Verifier string
+}
+
+func NewOIDCLogin() (*OIDCLogin, error) {
+	state, err := randomString()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomString()
+	if err != nil {
+		return nil, err
+	}
+	return &OIDCLogin{State: state, Nonce: nonce, Verifier: oauth2.GenerateVerifier()}, nil
+}
+
+// AuthCodeURL returns the URL of the provider's login page for a login.
+func (p *OIDCProvider) AuthCodeURL(login *OIDCLogin) string {
+	return p.config.AuthCodeURL(login.State, oidc.Nonce(login.Nonce), oauth2.S256ChallengeOption(login.Verifier))
+}
+
+// Exchange redeems the authorization code returned to the callback of a
+// login and returns the identity asserted by the validated ID token.
+func (p *OIDCProvider) Exchange(ctx context.Context, login *OIDCLogin, code string) (*models.OIDCIdentity, error) {
+	ctx = oidc.ClientContext(ctx, p.client)
+
+	token, err := p.config.Exchange(ctx, code, oauth2.VerifierOption(login.Verifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange authorization code: %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("token response has no ID token")
+	}
+
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: %w", err)
+	}
+	if idToken.Nonce != login.Nonce {
+		return nil, errors.New("invalid ID token: nonce does not match")
+	}
+
+	var claims struct {
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+		Name          string `json:"name"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("failed to parse ID token claims: %w", err)
+	}
+
+	return &models.OIDCIdentity{
+		Issuer:        idToken.Issuer,
+		Subject:       idToken.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Name:          claims.Name,
+	}, nil
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random string: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
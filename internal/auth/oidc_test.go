@@ -0,0 +1,125 @@
+package auth_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/url"
+	"testing"
+
+	"github.com/dmltdev/flashcards/internal/auth"
+	"github.com/dmltdev/flashcards/internal/testutil/oidctest"
+)
+
+const clientID = "flashcards"
+
+var alice = oidctest.Identity{Subject: "alice", Email: "Alice@Example.com", EmailVerified: true, Name: "Alice"}
+
+func newProvider(t *testing.T, issuer *oidctest.Issuer) *auth.OIDCProvider {
+	t.Helper()
+	provider, err := auth.NewOIDCProvider(context.Background(), issuer.Client(), issuer.URL, clientID, "secret", "http://localhost/auth/oidc/callback")
+	if err != nil {
+		t.Fatalf("NewOIDCProvider() error = %v", err)
+	}
+	return provider
+}
+
+// startLogin starts a login and signs alice in at the issuer.
+func startLogin(t *testing.T, issuer *oidctest.Issuer, provider *auth.OIDCProvider) (*auth.OIDCLogin, string) {
+	t.Helper()
+	login, err := auth.NewOIDCLogin()
+	if err != nil {
+		t.Fatalf("NewOIDCLogin() error = %v", err)
+	}
+	code, err := issuer.Authorize(provider.AuthCodeURL(login), alice)
+	if err != nil {
+		t.Fatalf("Authorize() error = %v", err)
+	}
+	return login, code
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	issuer := oidctest.NewIssuer(t, clientID)
+	provider := newProvider(t, issuer)
+
+	login, err := auth.NewOIDCLogin()
+	if err != nil {
+		t.Fatalf("NewOIDCLogin() error = %v", err)
+	}
+	u, err := url.Parse(provider.AuthCodeURL(login))
+	if err != nil {
+		t.Fatalf("AuthCodeURL() is not a URL: %v", err)
+	}
+
+	query := u.Query()
+	if query.Get("state") != login.State || query.Get("nonce") != login.Nonce {
+		t.Errorf("AuthCodeURL() state and nonce = %q, %q, want the login's", query.Get("state"), query.Get("nonce"))
+	}
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		t.Errorf("AuthCodeURL() has no S256 PKCE challenge: %s", u)
+	}
+	if query.Get("code_challenge") == login.Verifier {
+		t.Error("AuthCodeURL() leaks the PKCE verifier")
+	}
+}
+
+func TestExchange(t *testing.T) {
+	issuer := oidctest.NewIssuer(t, clientID)
+	provider := newProvider(t, issuer)
+	login, code := startLogin(t, issuer, provider)
+
+	identity, err := provider.Exchange(context.Background(), login, code)
+	if err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+
+	if identity.Issuer != issuer.URL || identity.Subject != alice.Subject {
+		t.Errorf("Exchange() identity = %s %s, want %s %s", identity.Issuer, identity.Subject, issuer.URL, alice.Subject)
+	}
+	if identity.Email != alice.Email || !identity.EmailVerified || identity.Name != alice.Name {
+		t.Errorf("Exchange() claims = %+v, want those of %+v", identity, alice)
+	}
+
+	if _, err := provider.Exchange(context.Background(), login, code); err == nil {
+		t.Error("Exchange() accepted an authorization code twice")
+	}
+}
+
+func TestExchangeRejects(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		setup func(issuer *oidctest.Issuer, login *auth.OIDCLogin)
+	}{
+		{"wrong PKCE verifier", func(issuer *oidctest.Issuer, login *auth.OIDCLogin) {
+			login.Verifier = "not-the-verifier-of-this-login-not-the-verifier-of-this-login"
+		}},
+		{"bad signature", func(issuer *oidctest.Issuer, login *auth.OIDCLogin) {
+			issuer.SigningKey = otherKey
+		}},
+		{"wrong audience", func(issuer *oidctest.Issuer, login *auth.OIDCLogin) {
+			issuer.Audience = "another-client"
+		}},
+		{"nonce mismatch", func(issuer *oidctest.Issuer, login *auth.OIDCLogin) {
+			login.Nonce = "another-nonce"
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer := oidctest.NewIssuer(t, clientID)
+			provider := newProvider(t, issuer)
+			login, code := startLogin(t, issuer, provider)
+
+			tt.setup(issuer, login)
+
+			if identity, err := provider.Exchange(context.Background(), login, code); err == nil {
+				t.Errorf("Exchange() = %+v, want an error", identity)
+			}
+		})
+	}
+}
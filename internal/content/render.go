@@ -0,0 +1,113 @@
+package content
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+const (
+	FormatPlain    = "plain"
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+)
+
+var (
+	markdown = goldmark.New(goldmark.WithExtensions(extension.GFM))
+	policy   = newPolicy()
+
+	stripPolicy = bluemonday.StrictPolicy().AddSpaceWhenStrippingTag(true)
+
+	mediaRefPattern = regexp.MustCompile(`/media/([0-9a-f]{64})`)
+)
+
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("class").Matching(bluemonday.SpaceSeparatedTokens).OnElements("code", "pre", "span")
+	p.AllowElements("audio", "source")
+	p.AllowAttrs("controls").OnElements("audio")
+	p.AllowAttrs("type").OnElements("source")
+	p.AllowAttrs("src").OnElements("audio", "source")
+	return p
+}
+
+func IsValidFormat(format string) bool {
+	switch format {
+	case FormatPlain, FormatMarkdown, FormatHTML:
+		return true
+	}
+	return false
+}
+
+// Render converts raw card text in the given format into HTML that is safe
+// to display in clients. Everything goes through the sanitizer, including
+// the output of the markdown renderer.
+func Render(format, text string) (string, error) {
+	switch format {
+	case FormatPlain, "":
+		return strings.ReplaceAll(html.EscapeString(text), "\n", "<br>"), nil
+	case FormatMarkdown:
+		var buf bytes.Buffer
+		if err := markdown.Convert([]byte(text), &buf); err != nil {
+			return "", fmt.Errorf("failed to render markdown: %w", err)
+		}
+		return policy.Sanitize(buf.String()), nil
+	case FormatHTML:
+		return policy.Sanitize(text), nil
+	}
+	return "", fmt.Errorf("unknown content format: %s", format)
+}
+
+// MediaReferences returns the checksums of all media files referenced from
+// the text through /media/{checksum} URLs, without duplicates.
+func MediaReferences(text string) []string {
+	var refs []string
+	seen := make(map[string]bool)
+	for _, match := range mediaRefPattern.FindAllStringSubmatch(text, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			refs = append(refs, match[1])
+		}
+	}
+	return refs
+}
+
+// Highlight turns a search snippet into HTML with the matches wrapped in
+// <mark> elements. The snippet is rendered card HTML with its tags removed,
+// so it holds text with character references but no markup, and the start
+// and stop markers, which contain a "<", can only come from the database.
+func Highlight(snippet, start, stop string) string {
+	parts := strings.Split(snippet, start)
+
+	var b strings.Builder
+	b.WriteString(escapeText(parts[0]))
+	for _, part := range parts[1:] {
+		match, rest, _ := strings.Cut(part, stop)
+		b.WriteString("<mark>" + escapeText(match) + "</mark>" + escapeText(rest))
+	}
+	return b.String()
+}
+
+// escapeText escapes text that may already contain character references,
+// possibly cut short, without escaping them twice.
+func escapeText(text string) string {
+	return html.EscapeString(html.UnescapeString(text))
+}
+
+// Normalize reduces card text to a form that ignores case, whitespace and
+// markup, so that "**Hello**  world" in markdown equals "hello world" in
+// plain text. It is used to detect duplicate cards.
+func Normalize(format, text string) (string, error) {
+	rendered, err := Render(format, text)
+	if err != nil {
+		return "", err
+	}
+	plain := html.UnescapeString(stripPolicy.Sanitize(rendered))
+	return strings.ToLower(strings.Join(strings.Fields(plain), " ")), nil
+}
@@ -0,0 +1,132 @@
+package content
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		text   string
+		want   string
+	}{
+		{"plain escapes markup", FormatPlain, "<b>bold</b> & co", "&lt;b&gt;bold&lt;/b&gt; &amp; co"},
+		{"plain keeps line breaks", FormatPlain, "one\ntwo", "one<br>two"},
+		{"empty format is plain", "", "a < b", "a &lt; b"},
+		{"markdown emphasis", FormatMarkdown, "**hello** world", "<p><strong>hello</strong> world</p>\n"},
+		{"markdown strikethrough", FormatMarkdown, "~~gone~~", "<p><del>gone</del></p>\n"},
+		{"markdown drops raw html", FormatMarkdown, "<script>alert(1)</script>", "\n"},
+		{"html keeps safe markup", FormatHTML, "<p><em>hi</em></p>", "<p><em>hi</em></p>"},
+		{"html strips scripts", FormatHTML, "<p>hi</p><script>alert(1)</script>", "<p>hi</p>"},
+		{"html strips event handlers", FormatHTML, `<img src="a.png" onerror="alert(1)">`, `<img src="a.png">`},
+		{"html strips javascript links", FormatHTML, `<a href="javascript:alert(1)">x</a>`, "x"},
+		{"html keeps audio", FormatHTML, `<audio controls src="/media/x"></audio>`, `<audio controls="" src="/media/x"></audio>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render(tt.format, tt.text)
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderUnknownFormat(t *testing.T) {
+	if _, err := Render("latex", "x"); err == nil {
+		t.Error("Render() expected an error for an unknown format")
+	}
+}
+
+func TestMediaReferences(t *testing.T) {
+	a := strings.Repeat("a", 64)
+	b := strings.Repeat("b", 64)
+
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"none", "no media here", nil},
+		{"one", `<img src="/media/` + a + `">`, []string{a}},
+		{"deduplicated", "/media/" + a + " /media/" + b + " /media/" + a, []string{a, b}},
+		{"short checksum", "/media/abc", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MediaReferences(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MediaReferences() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name    string
+		snippet string
+		want    string
+	}{
+		{"no match", "plain text", "plain text"},
+		{"one match", "a <hl>word</hl> here", "a <mark>word</mark> here"},
+		{"two matches", "<hl>a</hl> and <hl>b</hl>", "<mark>a</mark> and <mark>b</mark>"},
+		{"keeps character references", "fish &amp; <hl>chips</hl>", "fish &amp; <mark>chips</mark>"},
+		{"escaped markup stays text", "&lt;b&gt; <hl>bold</hl>", "&lt;b&gt; <mark>bold</mark>"},
+		{"literal markers in text", "[[hl]]word[[/hl]]", "[[hl]]word[[/hl]]"},
+		{"cut reference", "a &am", "a &amp;am"},
+		{"unterminated match", "a <hl>word", "a <mark>word</mark>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Highlight(tt.snippet, "<hl>", "</hl>"); got != tt.want {
+				t.Errorf("Highlight() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		text   string
+		want   string
+	}{
+		{"lowercases", FormatPlain, "Hello World", "hello world"},
+		{"collapses whitespace", FormatPlain, "  hello \n\t world  ", "hello world"},
+		{"strips markdown", FormatMarkdown, "**Hello**  _world_", "hello world"},
+		{"strips html", FormatHTML, "<p>Hello</p><p>world</p>", "hello world"},
+		{"unescapes entities", FormatPlain, "fish & chips", "fish & chips"},
+		{"empty", FormatPlain, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Normalize(tt.format, tt.text)
+			if err != nil {
+				t.Fatalf("Normalize() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Normalize() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeFormatsAgree(t *testing.T) {
+	plain, _ := Normalize(FormatPlain, "hello world")
+	markdown, _ := Normalize(FormatMarkdown, "**Hello**  world")
+	html, _ := Normalize(FormatHTML, "<b>HELLO</b> world")
+	if plain != markdown || plain != html {
+		t.Errorf("Normalize() = %q, %q, %q, want all equal", plain, markdown, html)
+	}
+}
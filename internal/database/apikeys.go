@@ -0,0 +1,81 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/dmltdev/flashcards/internal/models"
+)
+
+// CreateAPIKey stores a key of the user under the hash of its secret.
+func (db *DB) CreateAPIKey(key *models.APIKey, keyHash string) error {
+	query := `
+		INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		RETURNING id, created_at`
+
+	err := db.QueryRow(query, key.UserID, key.Name, key.Prefix, keyHash, key.Scopes, key.ExpiresAt).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create API key: %w", err)
+	}
+	return nil
+}
+
+// GetAPIKeys lists the keys of the user that have not been revoked,
+// including expired ones.
+func (db *DB) GetAPIKeys(userID int) ([]models.APIKey, error) {
+	keys := []models.APIKey{}
+	query := `
+		SELECT id, user_id, name, prefix, scopes, expires_at, last_used_at, created_at
+		FROM api_keys
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY created_at DESC`
+
+	if err := db.Select(&keys, query, userID); err != nil {
+		return nil, fmt.Errorf("failed to get API keys: %w", err)
+	}
+	return keys, nil
+}
+
+// UseAPIKey looks up the active key with the given hash and records that it
+// was used. It returns nil if there is no such key or it has expired.
+func (db *DB) UseAPIKey(keyHash string) (*models.APIKey, error) {
+	var key models.APIKey
+	query := `
+		UPDATE api_keys
+		SET last_used_at = NOW()
+		WHERE key_hash = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
+		RETURNING id, user_id, name, prefix, scopes, expires_at, last_used_at, created_at`
+
+	err := db.Get(&key, query, keyHash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to use API key: %w", err)
+	}
+	return &key, nil
+}
+
+func (db *DB) RevokeAPIKey(userID, id int) error {
+	query := `
+		UPDATE api_keys
+		SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`
+
+	result, err := db.Exec(query, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("API key not found")
+	}
+
+	return nil
+}
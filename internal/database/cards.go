@@ -2,30 +2,98 @@ package database
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"strconv"
 
 	"github.com/dmltdev/flashcards/internal/models"
+	"github.com/dmltdev/flashcards/internal/tagexpr"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
-func (db *DB) CreateCard(card *models.Card) error {
+var ErrNoDueCard = errors.New("no card is due")
+
+func (db *DB) CreateCard(userID int, card *models.Card) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := createCard(tx, userID, card); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// ImportCards stores a batch of cards in a single transaction. Cards with an
+// ID are merged into the existing card with that ID, the others are created.
+func (db *DB) ImportCards(userID int, cards []*models.Card) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, card := range cards {
+		if card.ID > 0 {
+			err = mergeCard(tx, userID, card.ID, card)
+		} else {
+			err = createCard(tx, userID, card)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func createCard(tx *sqlx.Tx, userID int, card *models.Card) error {
 	query := `
-		INSERT INTO cards (deck_id, front, back, created_at, updated_at)
-		VALUES ($1, $2, $3, NOW(), NOW())
+		INSERT INTO cards (deck_id, front, back, format, front_html, back_html, front_normalized, updated_by, created_at, updated_at)
+		SELECT $1, $2, $3, $4, $5, $6, $7, $8, NOW(), NOW()
+		WHERE EXISTS (
+			SELECT 1 FROM decks
+			WHERE id = $1 AND id IN (SELECT user_decks($9, 'editor')) AND kind = 'normal' AND deleted_at IS NULL
+		)
 		RETURNING id, created_at, updated_at`
 
-	err := db.QueryRow(query, card.DeckID, card.Front, card.Back).Scan(
+	err := tx.QueryRow(query, card.DeckID, card.Front, card.Back, card.Format, card.FrontHTML, card.BackHTML, card.FrontNormalized, card.UpdatedBy, userID).Scan(
 		&card.ID, &card.CreatedAt, &card.UpdatedAt)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("deck not found")
+		}
 		return fmt.Errorf("failed to create card: %w", err)
 	}
-	return nil
+
+	if err := linkCardMedia(tx, card); err != nil {
+		return err
+	}
+
+	_, err = addTags(tx, []int{card.ID}, card.Tags)
+	return err
 }
 
-func (db *DB) GetCard(id int) (*models.Card, error) {
+func (db *DB) GetCard(userID, id int) (*models.Card, error) {
 	var card models.Card
-	query := `SELECT id, deck_id, front, back, created_at, updated_at FROM cards WHERE id = $1`
+	query := `
+		SELECT c.id, c.deck_id, c.front, c.back, c.format, c.front_html, c.back_html, ` + cardSuspendedColumn("$2") + `, c.created_at, c.updated_at,
+		` + cardTagsColumn + `
+		FROM cards c
+		WHERE c.id = $1 AND c.deleted_at IS NULL
+			AND c.deck_id IN (SELECT user_decks($2, 'viewer'))`
 	
-	err := db.Get(&card, query, id)
+	err := db.Get(&card, query, id, userID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("card not found")
@@ -35,37 +103,102 @@ func (db *DB) GetCard(id int) (*models.Card, error) {
 	return &card, nil
 }
 
-func (db *DB) GetCardsByDeck(deckID int) ([]models.Card, error) {
+func (db *DB) GetCardsByIDs(userID int, ids []int) ([]models.Card, error) {
 	var cards []models.Card
-	query := `SELECT id, deck_id, front, back, created_at, updated_at FROM cards WHERE deck_id = $1 ORDER BY created_at DESC`
+	query := `
+		SELECT c.id, c.deck_id, c.front, c.back, c.format, c.front_html, c.back_html, ` + cardSuspendedColumn("$2") + `, c.created_at, c.updated_at,
+		` + cardTagsColumn + `
+		FROM cards c
+		WHERE c.id = ANY($1) AND c.deleted_at IS NULL
+			AND c.deck_id IN (SELECT user_decks($2, 'viewer'))
+		ORDER BY c.id`
+
+	err := db.Select(&cards, query, pq.Array(ids), userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cards: %w", err)
+	}
+	return cards, nil
+}
+
+// GetCardsByDeck returns the cards of a deck, optionally filtered by a tag
+// expression. A nil filter returns all cards.
+func (db *DB) GetCardsByDeck(userID, deckID int, filter tagexpr.Expr) ([]models.Card, error) {
+	var cards []models.Card
+	condition, args := tagFilterSQL(filter, []any{deckID, userID})
+	query := `
+		SELECT c.id, c.deck_id, c.front, c.back, c.format, c.front_html, c.back_html, ` + cardSuspendedColumn("$2") + `, c.created_at, c.updated_at,
+		` + cardTagsColumn + `
+		FROM cards c
+		WHERE c.deck_id = $1 AND c.deck_id IN (SELECT user_decks($2, 'viewer'))
+			AND c.deleted_at IS NULL AND ` + condition + `
+		ORDER BY c.created_at DESC`
 	
-	err := db.Select(&cards, query, deckID)
+	err := db.Select(&cards, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get cards by deck: %w", err)
 	}
 	return cards, nil
 }
 
-func (db *DB) GetNextDueCard(deckID int) (*models.Card, error) {
+// GetNextDueCard returns the card of the deck or any of its subdecks that is
+// due the soonest for the user, leaving out the cards the user suspended,
+// optionally restricted to cards matching a tag expression. The daily limits
+// of the options apply to the whole subtree, and new cards come in the order
+// they set.
+func (db *DB) GetNextDueCard(userID, deckID int, filter tagexpr.Expr, options models.DeckOptions) (*models.Card, error) {
 	var card models.Card
-	query := `
-	  	SELECT c.id, c.deck_id, c.front, c.back, c.created_at, c.updated_at
+	condition, args := tagFilterSQL(filter, []any{deckID, userID})
+	args = append(args, *options.NewCardsPerDay, *options.ReviewsPerDay)
+	newLimit, reviewLimit := "$"+strconv.Itoa(len(args)-1), "$"+strconv.Itoa(len(args))
+
+	newOrder := "c.created_at, c.id"
+	if *options.NewCardOrder == models.NewCardOrderRandom {
+		newOrder = "random()"
+	}
+
+	// New cards count once studied for the first time today, reviews once
+	// the card was first studied before today
+	query := deckSubtreeCTE(models.DeckRoleViewer) + `,
+	today AS (
+		SELECT
+			COUNT(*) FILTER (WHERE NOT EXISTS (
+				SELECT 1 FROM reviews pr
+				WHERE pr.card_id = tr.card_id AND pr.user_id = $2 AND pr.reviewed_at < tr.reviewed_at
+			)) AS new_cards,
+			COUNT(*) FILTER (WHERE EXISTS (
+				SELECT 1 FROM reviews pr
+				WHERE pr.card_id = tr.card_id AND pr.user_id = $2 AND pr.reviewed_at < date_trunc('day', NOW())
+			)) AS reviews
+		FROM reviews tr
+		JOIN cards tc ON tc.id = tr.card_id
+		WHERE tr.user_id = $2 AND tr.reviewed_at >= date_trunc('day', NOW())
+			AND tc.deck_id IN (SELECT id FROM subtree)
+	)
+	  	SELECT c.id, c.deck_id, c.front, c.back, c.format, c.front_html, c.back_html, ` + cardSuspendedColumn("$2") + `, c.created_at, c.updated_at,
+		` + cardTagsColumn + `
 	  	FROM cards c
-	  	LEFT JOIN (
-	 		SELECT DISTINCT ON (card_id) card_id, next_review_at
-			FROM reviews
-			ORDER BY card_id, reviewed_at DESC 
-	  	) r ON c.id = r.card_id
-		WHERE c.deck_id = $1
+	  	LEFT JOIN (` + latestReviews("$2") + `) r ON c.id = r.card_id
+		WHERE c.deck_id IN (SELECT id FROM subtree)
+			AND c.deleted_at IS NULL
+			AND NOT EXISTS (SELECT 1 FROM card_suspensions cs WHERE cs.card_id = c.id AND cs.user_id = $2)
 			AND (r.next_review_at IS NULL OR r.next_review_at <= NOW())
-		ORDER BY r.next_review_at ASC NULLS FIRST
+			AND ` + condition + `
+			AND CASE
+				WHEN r.card_id IS NULL THEN (SELECT new_cards FROM today) < ` + newLimit + `
+				WHEN EXISTS (
+					SELECT 1 FROM reviews pr
+					WHERE pr.card_id = c.id AND pr.user_id = $2 AND pr.reviewed_at < date_trunc('day', NOW())
+				) THEN (SELECT reviews FROM today) < ` + reviewLimit + `
+				ELSE TRUE
+			END
+		ORDER BY r.next_review_at ASC NULLS FIRST, ` + newOrder + `
 		LIMIT 1
 	`
 
-	err := db.Get(&card, query, deckID)
+	err := db.Get(&card, query, args...)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("next due card not found")
+			return nil, ErrNoDueCard
 		}
 		return nil, fmt.Errorf("failed to get next due card: %w", err)
 	}
@@ -73,27 +206,78 @@ func (db *DB) GetNextDueCard(deckID int) (*models.Card, error) {
 	return &card, nil
 }
 
-func (db *DB) UpdateCard(card *models.Card) error {
+func (db *DB) UpdateCard(userID int, card *models.Card) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := updateCard(tx, userID, card); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// UpdateCards stores the new content of several cards in one transaction.
+func (db *DB) UpdateCards(userID int, cards []*models.Card) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, card := range cards {
+		if err := updateCard(tx, userID, card); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// updateCard stores the new content of a card the user can edit. The previous
+// content is kept as a revision when it differs from the new one.
+func updateCard(tx *sqlx.Tx, userID int, card *models.Card) error {
+	if err := saveCardRevision(tx, card); err != nil {
+		return err
+	}
+
 	query := `
 		UPDATE cards 
-		SET front = $1, back = $2, updated_at = NOW()
-		WHERE id = $3
+		SET front = $1, back = $2, format = $3, front_html = $4, back_html = $5, front_normalized = $6, updated_by = $7, updated_at = NOW()
+		WHERE id = $8 AND deleted_at IS NULL
+			AND deck_id IN (SELECT user_decks($9, 'editor'))
 		RETURNING updated_at`
 
-	err := db.QueryRow(query, card.Front, card.Back, card.ID).Scan(&card.UpdatedAt)
+	err := tx.QueryRow(query, card.Front, card.Back, card.Format, card.FrontHTML, card.BackHTML, card.FrontNormalized, card.UpdatedBy, card.ID, userID).Scan(&card.UpdatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return fmt.Errorf("card not found")
+			return ErrCardNotFound
 		}
 		return fmt.Errorf("failed to update card: %w", err)
 	}
-	return nil
+
+	return linkCardMedia(tx, card)
 }
 
-func (db *DB) DeleteCard(id int) error {
-	query := `DELETE FROM cards WHERE id = $1`
+// DeleteCard moves a card to the trash. It stays in the database with its
+// review history until it is restored or purged.
+func (db *DB) DeleteCard(userID, id int) error {
+	query := `
+		UPDATE cards
+		SET deck_id = COALESCE(original_deck_id, deck_id), original_deck_id = NULL, deleted_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL
+			AND deck_id IN (SELECT user_decks($2, 'editor'))`
 	
-	result, err := db.Exec(query, id)
+	result, err := db.Exec(query, id, userID)
 	if err != nil {
 		return fmt.Errorf("failed to delete card: %w", err)
 	}
@@ -109,3 +293,44 @@ func (db *DB) DeleteCard(id int) error {
 
 	return nil
 }
+
+// SetCardSuspended suspends or unsuspends a card the user can view for that
+// user only. Suspended cards keep their reviews but are left out of the
+// user's study and filtered decks.
+func (db *DB) SetCardSuspended(userID, id int, suspended bool) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	existsQuery := `
+		SELECT EXISTS (
+			SELECT 1 FROM cards
+			WHERE id = $1 AND deleted_at IS NULL
+				AND deck_id IN (SELECT user_decks($2, 'viewer'))
+		)`
+	if err := tx.Get(&exists, existsQuery, id, userID); err != nil {
+		return fmt.Errorf("failed to get card: %w", err)
+	}
+	if !exists {
+		return ErrCardNotFound
+	}
+
+	query := `DELETE FROM card_suspensions WHERE user_id = $1 AND card_id = $2`
+	if suspended {
+		query = `
+			INSERT INTO card_suspensions (user_id, card_id, created_at)
+			VALUES ($1, $2, NOW())
+			ON CONFLICT (user_id, card_id) DO NOTHING`
+	}
+	if _, err := tx.Exec(query, userID, id); err != nil {
+		return fmt.Errorf("failed to suspend card: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
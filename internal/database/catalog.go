@@ -0,0 +1,483 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/dmltdev/flashcards/internal/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+var ErrAlreadySubscribed = errors.New("already subscribed to this deck")
+
+// publicationColumns selects a publication p with the details of its latest
+// version v and its publisher u.
+const publicationColumns = `
+	p.id, p.deck_id, p.user_id, u.name AS publisher, p.name, p.description, p.version, v.notes, v.card_count,
+	(SELECT COUNT(*) FROM subscriptions s WHERE s.publication_id = p.id) AS subscribers,
+	p.created_at, p.updated_at`
+
+const publicationJoins = `
+	JOIN users u ON u.id = p.user_id
+	JOIN publication_versions v ON v.publication_id = p.id AND v.version = p.version`
+
+// PublishDeck publishes the current content of a deck the user is an owner
+// of to the catalog as its next version. The first version adds the deck to
+// the catalog under the name of the deck. The preset of the deck stays with
+// the publisher.
+func (db *DB) PublishDeck(userID, deckID int, opts models.PublishOptions) (*models.Publication, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Locking the deck serializes version numbers and keeps the content
+	// consistent with the version
+	var locked int
+	lockQuery := `SELECT id FROM decks WHERE id = $1 AND id IN (SELECT user_decks($2, 'owner')) AND kind = 'normal' AND deleted_at IS NULL FOR UPDATE`
+	if err := tx.Get(&locked, lockQuery, deckID, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("deck not found")
+		}
+		return nil, fmt.Errorf("failed to lock deck: %w", err)
+	}
+
+	content, err := deckContent(tx, userID, deckID)
+	if err != nil {
+		return nil, err
+	}
+	content.PresetID = nil
+
+	var id int
+	query := `
+		INSERT INTO publications (deck_id, user_id, name, description, version, created_at, updated_at)
+		SELECT id, user_id, COALESCE(NULLIF($2, ''), name), $3, 1, NOW(), NOW()
+		FROM decks WHERE id = $1
+		ON CONFLICT (deck_id) DO UPDATE SET
+			name = COALESCE(NULLIF($2, ''), publications.name),
+			description = COALESCE(NULLIF($3, ''), publications.description),
+			version = publications.version + 1,
+			updated_at = NOW()
+		RETURNING id`
+
+	if err := tx.Get(&id, query, deckID, opts.Name, opts.Description); err != nil {
+		return nil, fmt.Errorf("failed to publish deck: %w", err)
+	}
+
+	versionQuery := `
+		INSERT INTO publication_versions (publication_id, version, notes, card_count, content, created_at)
+		SELECT id, version, $2, $3, $4, NOW()
+		FROM publications WHERE id = $1`
+
+	if _, err := tx.Exec(versionQuery, id, opts.Notes, len(content.Cards), content); err != nil {
+		return nil, fmt.Errorf("failed to create publication version: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return db.GetPublication(id)
+}
+
+// GetCatalog lists the published decks, optionally those whose name or
+// description contains q, with the most subscribed first.
+func (db *DB) GetCatalog(q string, limit, offset int) (*models.Catalog, error) {
+	filter := `WHERE $1 = '' OR p.name ILIKE '%' || $1 || '%' OR p.description ILIKE '%' || $1 || '%'`
+
+	var total int
+	countQuery := `SELECT COUNT(*) FROM publications p ` + filter
+	if err := db.Get(&total, countQuery, q); err != nil {
+		return nil, fmt.Errorf("failed to count publications: %w", err)
+	}
+
+	publications := []models.Publication{}
+	query := `
+		SELECT ` + publicationColumns + `
+		FROM publications p ` + publicationJoins + `
+		` + filter + `
+		ORDER BY subscribers DESC, p.updated_at DESC, p.id
+		LIMIT $2 OFFSET $3`
+
+	if err := db.Select(&publications, query, q, limit, offset); err != nil {
+		return nil, fmt.Errorf("failed to get publications: %w", err)
+	}
+
+	return &models.Catalog{
+		Publications: publications,
+		Total:        total,
+		Limit:        limit,
+		Offset:       offset,
+	}, nil
+}
+
+// GetPublication returns a published deck with the content of its latest
+// version.
+func (db *DB) GetPublication(id int) (*models.Publication, error) {
+	var publication models.Publication
+	query := `
+		SELECT ` + publicationColumns + `, v.content
+		FROM publications p ` + publicationJoins + `
+		WHERE p.id = $1`
+
+	err := db.Get(&publication, query, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("publication not found")
+		}
+		return nil, fmt.Errorf("failed to get publication: %w", err)
+	}
+	return &publication, nil
+}
+
+// UnpublishDeck removes a deck from the catalog. Subscribers keep their
+// copies but no longer receive updates.
+func (db *DB) UnpublishDeck(userID, id int) error {
+	query := `
+		DELETE FROM publications
+		WHERE id = $1 AND (user_id = $2 OR deck_id IN (SELECT user_decks($2, 'owner')))`
+
+	result, err := db.Exec(query, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to unpublish deck: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("publication not found")
+	}
+
+	return nil
+}
+
+// Subscribe gives the user their own copy of the latest version of a
+// publication in a new top level deck. The given cards are the rendered
+// cards of that version, with the IDs of the published cards.
+func (db *DB) Subscribe(userID int, publication *models.Publication, cards []*models.Card) (*models.Subscription, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var deckID int
+	deckQuery := `
+		INSERT INTO decks (user_id, name, options, kind, created_at, updated_at)
+		VALUES ($1, $2, $3, 'normal', NOW(), NOW())
+		RETURNING id`
+
+	err = tx.Get(&deckID, deckQuery, userID, publication.Name, publication.Content.Options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create deck: %w", err)
+	}
+
+	subscription := models.Subscription{
+		PublicationID: publication.ID,
+		UserID:        userID,
+		DeckID:        deckID,
+		Name:          publication.Name,
+		Version:       publication.Version,
+		LatestVersion: publication.Version,
+	}
+	query := `
+		INSERT INTO subscriptions (publication_id, user_id, deck_id, version, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, NOW(), NOW())
+		RETURNING id, created_at, updated_at`
+
+	err = tx.QueryRow(query, publication.ID, userID, deckID, publication.Version).Scan(
+		&subscription.ID, &subscription.CreatedAt, &subscription.UpdatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrAlreadySubscribed
+		}
+		return nil, fmt.Errorf("failed to subscribe: %w", err)
+	}
+
+	for _, card := range cards {
+		card.DeckID = deckID
+		if err := createSubscribedCard(tx, userID, card); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &subscription, nil
+}
+
+// createSubscribedCard creates the copy of a published card, whose ID is
+// given as the ID of the card, and links it to the published card.
+func createSubscribedCard(tx *sqlx.Tx, userID int, card *models.Card) error {
+	sourceID := card.ID
+	card.ID = 0
+	if err := createCard(tx, userID, card); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`UPDATE cards SET source_card_id = $1 WHERE id = $2`, sourceID, card.ID); err != nil {
+		return fmt.Errorf("failed to link card: %w", err)
+	}
+	return nil
+}
+
+const subscriptionColumns = `
+	s.id, s.publication_id, s.user_id, s.deck_id, p.name, s.version, p.version AS latest_version, s.created_at, s.updated_at`
+
+func (db *DB) GetSubscriptions(userID int) ([]models.Subscription, error) {
+	subscriptions := []models.Subscription{}
+	query := `
+		SELECT ` + subscriptionColumns + `
+		FROM subscriptions s
+		JOIN publications p ON p.id = s.publication_id
+		WHERE s.user_id = $1
+		ORDER BY s.created_at DESC`
+
+	if err := db.Select(&subscriptions, query, userID); err != nil {
+		return nil, fmt.Errorf("failed to get subscriptions: %w", err)
+	}
+	for i := range subscriptions {
+		subscriptions[i].UpdateAvailable = subscriptions[i].Version < subscriptions[i].LatestVersion
+	}
+	return subscriptions, nil
+}
+
+func (db *DB) GetSubscription(userID, id int) (*models.Subscription, error) {
+	var subscription models.Subscription
+	query := `
+		SELECT ` + subscriptionColumns + `
+		FROM subscriptions s
+		JOIN publications p ON p.id = s.publication_id
+		WHERE s.id = $1 AND s.user_id = $2`
+
+	err := db.Get(&subscription, query, id, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("subscription not found")
+		}
+		return nil, fmt.Errorf("failed to get subscription: %w", err)
+	}
+	subscription.UpdateAvailable = subscription.Version < subscription.LatestVersion
+	return &subscription, nil
+}
+
+// GetPublicationVersion returns the content of a version of a publication.
+func (db *DB) GetPublicationVersion(publicationID, version int) (*models.SnapshotContent, error) {
+	return publicationVersion(db, publicationID, version)
+}
+
+func publicationVersion(q sqlx.Queryer, publicationID, version int) (*models.SnapshotContent, error) {
+	var content models.SnapshotContent
+	query := `SELECT content FROM publication_versions WHERE publication_id = $1 AND version = $2`
+
+	if err := sqlx.Get(q, &content, query, publicationID, version); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("publication version not found")
+		}
+		return nil, fmt.Errorf("failed to get publication version: %w", err)
+	}
+	return &content, nil
+}
+
+// UpdateSubscription pulls a newer version of a publication into the
+// subscriber's copy. The given cards are the rendered cards of that version,
+// with the IDs of the published cards. Copies keep their ID and review
+// history: new cards are added, cards removed by the publisher go to the
+// trash and edited cards are updated unless the subscriber edited them as
+// well. Cards the subscriber deleted are not brought back.
+func (db *DB) UpdateSubscription(userID, id, version int, cards []*models.Card) (*models.SubscriptionUpdate, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var subscription struct {
+		PublicationID int `db:"publication_id"`
+		DeckID        int `db:"deck_id"`
+		Version       int `db:"version"`
+	}
+	query := `
+		SELECT s.publication_id, s.deck_id, s.version
+		FROM subscriptions s
+		JOIN decks d ON d.id = s.deck_id AND d.deleted_at IS NULL
+		WHERE s.id = $1 AND s.user_id = $2
+		FOR UPDATE OF s`
+
+	if err := tx.Get(&subscription, query, id, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("subscription not found")
+		}
+		return nil, fmt.Errorf("failed to get subscription: %w", err)
+	}
+
+	update := models.SubscriptionUpdate{From: subscription.Version, To: version}
+	if subscription.Version >= version {
+		update.To = subscription.Version
+		return &update, nil
+	}
+
+	previous, err := publicationVersion(tx, subscription.PublicationID, subscription.Version)
+	if err != nil {
+		return nil, err
+	}
+	published := make(map[int]models.SnapshotCard, len(previous.Cards))
+	for _, card := range previous.Cards {
+		published[card.ID] = card
+	}
+
+	var copies []struct {
+		ID       int    `db:"id"`
+		SourceID int    `db:"source_card_id"`
+		Front    string `db:"front"`
+		Back     string `db:"back"`
+		Format   string `db:"format"`
+		Deleted  bool   `db:"deleted"`
+	}
+	copiesQuery := `
+		SELECT id, source_card_id, front, back, format, deleted_at IS NOT NULL AS deleted
+		FROM cards
+		WHERE COALESCE(original_deck_id, deck_id) = $1 AND source_card_id IS NOT NULL`
+
+	if err := tx.Select(&copies, copiesQuery, subscription.DeckID); err != nil {
+		return nil, fmt.Errorf("failed to get cards: %w", err)
+	}
+	local := make(map[int]int, len(copies))
+	for i, c := range copies {
+		local[c.SourceID] = i
+	}
+
+	current := make(map[int]bool, len(cards))
+	for _, card := range cards {
+		current[card.ID] = true
+		old, wasPublished := published[card.ID]
+
+		i, ok := local[card.ID]
+		if !ok {
+			// A published card without a copy was deleted and purged by
+			// the subscriber, unless it is new
+			if !wasPublished {
+				card.DeckID = subscription.DeckID
+				if err := createSubscribedCard(tx, userID, card); err != nil {
+					return nil, err
+				}
+				update.Added++
+			}
+			continue
+		}
+
+		existing := copies[i]
+		if existing.Deleted || !wasPublished {
+			continue
+		}
+
+		contentChanged := old.Front != card.Front || old.Back != card.Back || old.Format != card.Format
+		tagsChanged := !slices.Equal(old.Tags, []string(card.Tags))
+		if !contentChanged && !tagsChanged {
+			continue
+		}
+
+		if contentChanged {
+			if existing.Front != old.Front || existing.Back != old.Back || existing.Format != old.Format {
+				update.Kept++
+				continue
+			}
+			card.ID = existing.ID
+			if err := updateCard(tx, userID, card); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := updateCardTags(tx, existing.ID, old.Tags, card.Tags); err != nil {
+			return nil, err
+		}
+		update.Updated++
+	}
+
+	var removed []int
+	for _, c := range copies {
+		if _, ok := published[c.SourceID]; ok && !current[c.SourceID] && !c.Deleted {
+			removed = append(removed, c.ID)
+		}
+	}
+	if len(removed) > 0 {
+		trashQuery := `
+			UPDATE cards
+			SET deck_id = COALESCE(original_deck_id, deck_id), original_deck_id = NULL, deleted_at = NOW()
+			WHERE id = ANY($1)`
+
+		if _, err := tx.Exec(trashQuery, pq.Array(removed)); err != nil {
+			return nil, fmt.Errorf("failed to trash cards: %w", err)
+		}
+		update.Removed = len(removed)
+	}
+
+	versionQuery := `UPDATE subscriptions SET version = $1, updated_at = NOW() WHERE id = $2`
+	if _, err := tx.Exec(versionQuery, version, id); err != nil {
+		return nil, fmt.Errorf("failed to update subscription: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &update, nil
+}
+
+// updateCardTags applies the tag changes of a published card to its copy,
+// leaving the tags the subscriber added alone.
+func updateCardTags(tx *sqlx.Tx, cardID int, from, to []string) error {
+	var added, removed []string
+	for _, tag := range to {
+		if !slices.Contains(from, tag) {
+			added = append(added, tag)
+		}
+	}
+	for _, tag := range from {
+		if !slices.Contains(to, tag) {
+			removed = append(removed, tag)
+		}
+	}
+
+	if len(removed) > 0 {
+		query := `
+			DELETE FROM card_tags ct
+			USING tags t
+			WHERE t.id = ct.tag_id AND ct.card_id = $1 AND t.name = ANY($2)`
+
+		if _, err := tx.Exec(query, cardID, pq.Array(removed)); err != nil {
+			return fmt.Errorf("failed to remove card tags: %w", err)
+		}
+	}
+
+	_, err := addTags(tx, []int{cardID}, added)
+	return err
+}
+
+// Unsubscribe stops updates of the user's copy of a publication. The copy
+// stays with the user as a regular deck.
+func (db *DB) Unsubscribe(userID, id int) error {
+	query := `DELETE FROM subscriptions WHERE id = $1 AND user_id = $2`
+
+	result, err := db.Exec(query, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to unsubscribe: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("subscription not found")
+	}
+
+	return nil
+}
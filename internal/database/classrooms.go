@@ -0,0 +1,456 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/dmltdev/flashcards/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrInvalidJoinCode = errors.New("no classroom with this join code exists")
+	ErrLastTeacher     = errors.New("a classroom needs at least one teacher")
+	ErrAlreadyAssigned = errors.New("the deck is already assigned to this classroom")
+)
+
+// isTeacher returns a condition that holds when the user userParam teaches
+// the classroom classroomParam.
+func isTeacher(classroomParam, userParam string) string {
+	return `EXISTS (
+		SELECT 1 FROM classroom_members
+		WHERE classroom_id = ` + classroomParam + ` AND user_id = ` + userParam + ` AND role = 'teacher'
+	)`
+}
+
+// classroomColumns selects a classroom c as seen by the member m.
+const classroomColumns = `
+	c.id, c.name, c.description,
+	CASE WHEN m.role = 'teacher' THEN c.join_code ELSE '' END AS join_code,
+	m.role,
+	(SELECT COUNT(*) FROM classroom_members s WHERE s.classroom_id = c.id AND s.role = 'student') AS students,
+	c.created_at, c.updated_at`
+
+// CreateClassroom creates a classroom taught by the user.
+func (db *DB) CreateClassroom(userID int, classroom *models.Classroom) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO classrooms (name, description, join_code, created_at, updated_at)
+		VALUES ($1, $2, $3, NOW(), NOW())
+		RETURNING id, created_at, updated_at`
+
+	err = tx.QueryRow(query, classroom.Name, classroom.Description, classroom.JoinCode).Scan(
+		&classroom.ID, &classroom.CreatedAt, &classroom.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create classroom: %w", err)
+	}
+
+	memberQuery := `INSERT INTO classroom_members (classroom_id, user_id, role, joined_at) VALUES ($1, $2, 'teacher', NOW())`
+	if _, err := tx.Exec(memberQuery, classroom.ID, userID); err != nil {
+		return fmt.Errorf("failed to add teacher: %w", err)
+	}
+	classroom.Role = models.ClassroomRoleTeacher
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// GetClassrooms lists the classrooms the user teaches or attends.
+func (db *DB) GetClassrooms(userID int) ([]models.Classroom, error) {
+	classrooms := []models.Classroom{}
+	query := `
+		SELECT ` + classroomColumns + `
+		FROM classrooms c
+		JOIN classroom_members m ON m.classroom_id = c.id AND m.user_id = $1
+		ORDER BY c.name, c.id`
+
+	if err := db.Select(&classrooms, query, userID); err != nil {
+		return nil, fmt.Errorf("failed to get classrooms: %w", err)
+	}
+	return classrooms, nil
+}
+
+func (db *DB) GetClassroom(userID, id int) (*models.Classroom, error) {
+	var classroom models.Classroom
+	query := `
+		SELECT ` + classroomColumns + `
+		FROM classrooms c
+		JOIN classroom_members m ON m.classroom_id = c.id AND m.user_id = $2
+		WHERE c.id = $1`
+
+	err := db.Get(&classroom, query, id, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("classroom not found")
+		}
+		return nil, fmt.Errorf("failed to get classroom: %w", err)
+	}
+	return &classroom, nil
+}
+
+// UpdateClassroom stores the name and description of a classroom the user
+// teaches.
+func (db *DB) UpdateClassroom(userID int, classroom *models.Classroom) error {
+	query := `
+		UPDATE classrooms
+		SET name = $1, description = $2, updated_at = NOW()
+		WHERE id = $3 AND ` + isTeacher("$3", "$4") + `
+		RETURNING updated_at`
+
+	err := db.QueryRow(query, classroom.Name, classroom.Description, classroom.ID, userID).Scan(&classroom.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("classroom not found")
+		}
+		return fmt.Errorf("failed to update classroom: %w", err)
+	}
+	return nil
+}
+
+// DeleteClassroom deletes a classroom the user teaches with its memberships
+// and assignments. The assigned decks are left alone.
+func (db *DB) DeleteClassroom(userID, id int) error {
+	query := `DELETE FROM classrooms WHERE id = $1 AND ` + isTeacher("$1", "$2")
+
+	result, err := db.Exec(query, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete classroom: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("classroom not found")
+	}
+
+	return nil
+}
+
+// JoinClassroom adds the user as a student to the classroom with the join
+// code and returns its ID. Joining a classroom again keeps the role.
+func (db *DB) JoinClassroom(userID int, joinCode string) (int, error) {
+	var id int
+	query := `
+		WITH classroom AS (SELECT id FROM classrooms WHERE join_code = $1),
+		joined AS (
+			INSERT INTO classroom_members (classroom_id, user_id, role, joined_at)
+			SELECT id, $2, 'student', NOW() FROM classroom
+			ON CONFLICT (classroom_id, user_id) DO NOTHING
+		)
+		SELECT id FROM classroom`
+
+	if err := db.Get(&id, query, joinCode, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrInvalidJoinCode
+		}
+		return 0, fmt.Errorf("failed to join classroom: %w", err)
+	}
+	return id, nil
+}
+
+// GetClassroomMembers lists the members of a classroom the user teaches.
+func (db *DB) GetClassroomMembers(userID, classroomID int) ([]models.ClassroomMember, error) {
+	members := []models.ClassroomMember{}
+	query := `
+		SELECT m.classroom_id, m.user_id, u.email, u.name, m.role, m.joined_at
+		FROM classroom_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.classroom_id = $1 AND ` + isTeacher("$1", "$2") + `
+		ORDER BY m.role DESC, u.name, u.email`
+
+	if err := db.Select(&members, query, classroomID, userID); err != nil {
+		return nil, fmt.Errorf("failed to get classroom members: %w", err)
+	}
+	return members, nil
+}
+
+// SetClassroomMember adds the user having member.Email to a classroom the
+// user teaches, or changes the role of an existing member.
+func (db *DB) SetClassroomMember(userID int, member *models.ClassroomMember) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var teacher bool
+	if err := tx.Get(&teacher, `SELECT `+isTeacher("$1", "$2"), member.ClassroomID, userID); err != nil {
+		return fmt.Errorf("failed to get classroom: %w", err)
+	}
+	if !teacher {
+		return fmt.Errorf("classroom not found")
+	}
+
+	var user models.User
+	if err := tx.Get(&user, `SELECT id, name FROM users WHERE email = $1`, member.Email); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrUserNotFound
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	query := `
+		INSERT INTO classroom_members (classroom_id, user_id, role, joined_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (classroom_id, user_id) DO UPDATE SET role = EXCLUDED.role
+		RETURNING joined_at`
+
+	if err := tx.QueryRow(query, member.ClassroomID, user.ID, member.Role).Scan(&member.JoinedAt); err != nil {
+		return fmt.Errorf("failed to set classroom member: %w", err)
+	}
+	member.UserID = user.ID
+	member.Name = user.Name
+
+	if err := checkTeachers(tx, member.ClassroomID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// RemoveClassroomMember removes the target user from a classroom. Teachers
+// can remove anyone and every member can leave.
+func (db *DB) RemoveClassroomMember(userID, classroomID, targetUserID int) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		DELETE FROM classroom_members
+		WHERE classroom_id = $1 AND user_id = $2
+			AND ($2 = $3 OR ` + isTeacher("$1", "$3") + `)`
+
+	result, err := tx.Exec(query, classroomID, targetUserID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to remove classroom member: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("classroom member not found")
+	}
+
+	if err := checkTeachers(tx, classroomID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// checkTeachers returns ErrLastTeacher when a change left the classroom
+// without a teacher.
+func checkTeachers(tx *sqlx.Tx, classroomID int) error {
+	var teachers int
+	query := `SELECT COUNT(*) FROM classroom_members WHERE classroom_id = $1 AND role = 'teacher'`
+	if err := tx.Get(&teachers, query, classroomID); err != nil {
+		return fmt.Errorf("failed to count teachers: %w", err)
+	}
+	if teachers == 0 {
+		return ErrLastTeacher
+	}
+	return nil
+}
+
+// CreateAssignment assigns a deck the user is an owner of to a classroom the
+// user teaches. Every member of the classroom can then view the deck.
+func (db *DB) CreateAssignment(userID int, assignment *models.Assignment) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO classroom_assignments (classroom_id, deck_id, due_at, target_mastery, created_at, updated_at)
+		SELECT $1, d.id, $3, $4, NOW(), NOW()
+		FROM decks d
+		WHERE d.id = $2 AND d.id IN (SELECT user_decks($5, 'owner')) AND d.kind = 'normal' AND d.deleted_at IS NULL
+			AND ` + isTeacher("$1", "$5") + `
+		RETURNING id, (SELECT name FROM decks WHERE id = $2) AS deck_name, created_at, updated_at`
+
+	err = tx.QueryRow(query, assignment.ClassroomID, assignment.DeckID, assignment.DueAt, assignment.TargetMastery, userID).Scan(
+		&assignment.ID, &assignment.DeckName, &assignment.CreatedAt, &assignment.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("deck not found")
+		}
+		if isUniqueViolation(err) {
+			return ErrAlreadyAssigned
+		}
+		return fmt.Errorf("failed to create assignment: %w", err)
+	}
+
+	if err := returnSharedCards(tx, assignment.DeckID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+const assignmentColumns = `
+	a.id, a.classroom_id, a.deck_id, d.name AS deck_name, a.due_at, a.target_mastery, a.created_at, a.updated_at`
+
+// GetAssignments lists the assignments of a classroom the user is a member
+// of, the soonest due first.
+func (db *DB) GetAssignments(userID, classroomID int) ([]models.Assignment, error) {
+	assignments := []models.Assignment{}
+	query := `
+		SELECT ` + assignmentColumns + `
+		FROM classroom_assignments a
+		JOIN decks d ON d.id = a.deck_id AND d.deleted_at IS NULL
+		JOIN classroom_members m ON m.classroom_id = a.classroom_id AND m.user_id = $2
+		WHERE a.classroom_id = $1
+		ORDER BY a.due_at NULLS LAST, a.id`
+
+	if err := db.Select(&assignments, query, classroomID, userID); err != nil {
+		return nil, fmt.Errorf("failed to get assignments: %w", err)
+	}
+	return assignments, nil
+}
+
+// UpdateAssignment stores the due date and target mastery of an assignment
+// of a classroom the user teaches.
+func (db *DB) UpdateAssignment(userID int, assignment *models.Assignment) error {
+	query := `
+		UPDATE classroom_assignments a
+		SET due_at = $1, target_mastery = $2, updated_at = NOW()
+		FROM decks d
+		WHERE a.id = $3 AND a.classroom_id = $4 AND d.id = a.deck_id AND ` + isTeacher("$4", "$5") + `
+		RETURNING ` + assignmentColumns
+
+	err := db.Get(assignment, query, assignment.DueAt, assignment.TargetMastery, assignment.ID, assignment.ClassroomID, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("assignment not found")
+		}
+		return fmt.Errorf("failed to update assignment: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) DeleteAssignment(userID, classroomID, id int) error {
+	query := `DELETE FROM classroom_assignments WHERE id = $1 AND classroom_id = $2 AND ` + isTeacher("$2", "$3")
+
+	result, err := db.Exec(query, id, classroomID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete assignment: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("assignment not found")
+	}
+
+	return nil
+}
+
+// GetAssignmentProgress reports the progress of the students of a classroom
+// on each assignment, computed from their reviews of the cards of the
+// assigned decks and their subdecks. Teachers see every student, or only
+// studentID when it is set, and students only see themselves. Retention
+// covers the last days days.
+func (db *DB) GetAssignmentProgress(userID, classroomID int, studentID *int, days int) ([]models.AssignmentProgress, error) {
+	progress := []models.AssignmentProgress{}
+	query := `
+		WITH RECURSIVE subtree AS (
+			SELECT ca.id AS assignment_id, ca.deck_id AS id
+			FROM classroom_assignments ca
+			WHERE ca.classroom_id = $1
+			UNION ALL
+			SELECT s.assignment_id, d.id
+			FROM decks d JOIN subtree s ON d.parent_id = s.id
+			WHERE d.deleted_at IS NULL
+		),
+		assigned_cards AS (
+			SELECT s.assignment_id, c.id AS card_id
+			FROM subtree s
+			JOIN cards c ON COALESCE(c.original_deck_id, c.deck_id) = s.id AND c.deleted_at IS NULL
+		),
+		students AS (
+			SELECT m.user_id
+			FROM classroom_members m
+			WHERE m.classroom_id = $1 AND m.role = 'student'
+				AND ($4::INTEGER IS NULL OR m.user_id = $4)
+				AND (m.user_id = $2 OR ` + isTeacher("$1", "$2") + `)
+		),
+		latest AS (
+			SELECT DISTINCT ON (r.user_id, r.card_id) r.user_id, r.card_id, r.quality, r.interval, r.reviewed_at, r.next_review_at
+			FROM reviews r
+			WHERE r.user_id IN (SELECT user_id FROM students)
+				AND r.card_id IN (SELECT card_id FROM assigned_cards)
+			ORDER BY r.user_id, r.card_id, r.reviewed_at DESC
+		),
+		recent AS (
+			SELECT ac.assignment_id, r.user_id, COUNT(*) AS reviews, COUNT(*) FILTER (WHERE r.quality >= 3) AS passed
+			FROM assigned_cards ac
+			JOIN reviews r ON r.card_id = ac.card_id
+			WHERE r.user_id IN (SELECT user_id FROM students)
+				AND r.reviewed_at >= NOW() - make_interval(days => $3)
+			GROUP BY ac.assignment_id, r.user_id
+		)
+		SELECT
+			a.id AS assignment_id,
+			a.deck_id,
+			st.user_id,
+			u.email,
+			u.name,
+			COUNT(ac.card_id) AS total_cards,
+			COUNT(l.card_id) AS studied,
+			COUNT(l.card_id) FILTER (WHERE l.quality >= 3 AND l.interval >= make_interval(secs => $5)) AS mastered,
+			COUNT(ac.card_id) FILTER (WHERE l.next_review_at < NOW() OR (l.card_id IS NULL AND a.due_at < NOW())) AS overdue,
+			COALESCE(rc.reviews, 0) AS reviews,
+			COALESCE(rc.passed, 0) AS passed,
+			MAX(l.reviewed_at) AS last_reviewed_at,
+			a.due_at,
+			COALESCE(a.due_at < NOW(), FALSE) AS past_due,
+			a.target_mastery
+		FROM classroom_assignments a
+		JOIN decks d ON d.id = a.deck_id AND d.deleted_at IS NULL
+		CROSS JOIN students st
+		JOIN users u ON u.id = st.user_id
+		LEFT JOIN assigned_cards ac ON ac.assignment_id = a.id
+		LEFT JOIN latest l ON l.card_id = ac.card_id AND l.user_id = st.user_id
+		LEFT JOIN recent rc ON rc.assignment_id = a.id AND rc.user_id = st.user_id
+		WHERE a.classroom_id = $1
+		GROUP BY a.id, st.user_id, u.email, u.name, rc.reviews, rc.passed
+		ORDER BY a.due_at NULLS LAST, a.id, u.name, u.email`
+
+	if err := db.Select(&progress, query, classroomID, userID, days, studentID, models.MatureInterval.Seconds()); err != nil {
+		return nil, fmt.Errorf("failed to get assignment progress: %w", err)
+	}
+
+	for i := range progress {
+		progress[i].Summarize(days)
+	}
+	return progress, nil
+}
@@ -0,0 +1,128 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/dmltdev/flashcards/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// CloneDeck deep-copies a deck the user can view with its cards into a new
+// deck of the user and returns the ID of the clone. The copied cards start
+// with fresh scheduling state and no history. Cards currently borrowed by a
+// filtered deck are copied with their home deck, while a cloned filtered
+// deck starts out empty. A clone of a deck shared with the user goes to the
+// top level by default and does not keep the owner's preset.
+func (db *DB) CloneDeck(userID, sourceID int, opts models.DeckCloneOptions, author string) (int, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var source struct {
+		Name     string `db:"name"`
+		ParentID *int   `db:"parent_id"`
+	}
+	query := `
+		SELECT name, CASE WHEN user_id = $2 THEN parent_id END AS parent_id
+		FROM decks
+		WHERE id = $1 AND id IN (SELECT user_decks($2, 'viewer')) AND deleted_at IS NULL`
+	if err := tx.Get(&source, query, sourceID, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("deck not found")
+		}
+		return 0, fmt.Errorf("failed to get deck: %w", err)
+	}
+
+	name := strings.TrimSpace(opts.Name)
+	if name == "" {
+		name = source.Name + " (copy)"
+	}
+	parentID := source.ParentID
+	if opts.ParentID != nil {
+		if err := checkDeckExists(tx, userID, *opts.ParentID); err != nil {
+			return 0, fmt.Errorf("parent %w", err)
+		}
+		parentID = opts.ParentID
+	}
+
+	// A clone with subdecks placed inside the source tree would be cloned
+	// into itself
+	if opts.Subdecks && parentID != nil {
+		var cycle bool
+		cycleQuery := deckSubtreeCTE(models.DeckRoleViewer) + `
+			SELECT EXISTS (SELECT 1 FROM subtree WHERE id = $3)`
+
+		if err := tx.Get(&cycle, cycleQuery, sourceID, userID, *parentID); err != nil {
+			return 0, fmt.Errorf("failed to check deck hierarchy: %w", err)
+		}
+		if cycle {
+			return 0, ErrDeckCycle
+		}
+	}
+
+	cloneID, err := cloneDeck(tx, userID, sourceID, parentID, name, opts, author)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return cloneID, nil
+}
+
+func cloneDeck(tx *sqlx.Tx, userID, sourceID int, parentID *int, name string, opts models.DeckCloneOptions, author string) (int, error) {
+	var cloneID int
+	query := `
+		INSERT INTO decks (user_id, name, parent_id, preset_id, options, kind, filter, created_at, updated_at)
+		SELECT $4, $1, $2, CASE WHEN user_id = $4 THEN preset_id END, options, kind, filter, NOW(), NOW()
+		FROM decks
+		WHERE id = $3
+		RETURNING id`
+
+	if err := tx.QueryRow(query, name, parentID, sourceID, userID).Scan(&cloneID); err != nil {
+		return 0, fmt.Errorf("failed to clone deck: %w", err)
+	}
+
+	var cardIDs []int
+	cardsQuery := `
+		SELECT id FROM cards
+		WHERE COALESCE(original_deck_id, deck_id) = $1 AND deleted_at IS NULL
+			AND EXISTS (SELECT 1 FROM decks WHERE id = $1 AND kind = 'normal')
+		ORDER BY created_at, id`
+
+	if err := tx.Select(&cardIDs, cardsQuery, sourceID); err != nil {
+		return 0, fmt.Errorf("failed to get cards: %w", err)
+	}
+
+	for _, cardID := range cardIDs {
+		if _, err := copyCard(tx, userID, cardID, cloneID, author, opts.Tags); err != nil {
+			return 0, err
+		}
+	}
+
+	if !opts.Subdecks {
+		return cloneID, nil
+	}
+
+	var children []struct {
+		ID   int    `db:"id"`
+		Name string `db:"name"`
+	}
+	childrenQuery := `SELECT id, name FROM decks WHERE parent_id = $1 AND deleted_at IS NULL ORDER BY id`
+	if err := tx.Select(&children, childrenQuery, sourceID); err != nil {
+		return 0, fmt.Errorf("failed to get subdecks: %w", err)
+	}
+
+	for _, child := range children {
+		if _, err := cloneDeck(tx, userID, child.ID, &cloneID, child.Name, opts, author); err != nil {
+			return 0, err
+		}
+	}
+
+	return cloneID, nil
+}
@@ -2,30 +2,76 @@ package database
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 
 	"github.com/dmltdev/flashcards/internal/models"
 )
 
+// deckSubtreeCTE selects the IDs of the live deck $1, on which the user $2
+// holds at least the given role, and all of its live subdecks as the
+// "subtree" relation. A role on a deck extends to its subdecks.
+func deckSubtreeCTE(role string) string {
+	return `
+	WITH RECURSIVE subtree AS (
+		SELECT id FROM decks WHERE id = $1 AND id IN (SELECT user_decks($2, '` + role + `')) AND deleted_at IS NULL
+		UNION ALL
+		SELECT d.id FROM decks d JOIN subtree s ON d.parent_id = s.id
+		WHERE d.deleted_at IS NULL
+	)`
+}
+
+// latestReviews selects the most recent review of every card by the user
+// bound to the given parameter. Collaborators on a shared deck study its
+// cards on their own schedules.
+func latestReviews(userParam string) string {
+	return `
+	SELECT DISTINCT ON (card_id) card_id, next_review_at
+	FROM reviews
+	WHERE user_id = ` + userParam + `
+	ORDER BY card_id, reviewed_at DESC`
+}
+
+var ErrDeckCycle = errors.New("deck cannot be nested inside itself or its subdecks")
+
 func (db *DB) CreateDeck(deck *models.Deck) error {
 	query := `
-		INSERT INTO decks (name, created_at, updated_at)
-		VALUES ($1, NOW(), NOW())
-		RETURNING id, created_at, updated_at`
+		INSERT INTO decks (user_id, name, parent_id, preset_id, options, kind, filter, created_at, updated_at)
+		SELECT $1, $2, $3, $4, $5, $6, $7, NOW(), NOW()
+		WHERE ($3::INTEGER IS NULL OR EXISTS (SELECT 1 FROM decks WHERE id = $3 AND user_id = $1 AND deleted_at IS NULL))
+			AND ($4::INTEGER IS NULL OR EXISTS (SELECT 1 FROM presets WHERE id = $4 AND user_id = $1))
+		RETURNING id, created_at, updated_at,
+			(SELECT options FROM presets WHERE id = $4) AS preset_options`
 
-	err := db.QueryRow(query, deck.Name).Scan(
-		&deck.ID, &deck.CreatedAt, &deck.UpdatedAt)
+	err := db.QueryRow(query, deck.UserID, deck.Name, deck.ParentID, deck.PresetID, deck.Options, deck.Kind, deck.Filter).Scan(
+		&deck.ID, &deck.CreatedAt, &deck.UpdatedAt, &deck.PresetOptions)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("parent deck or preset not found")
+		}
 		return fmt.Errorf("failed to create deck: %w", err)
 	}
+
+	deck.ResolveOptions()
 	return nil
 }
 
-func (db *DB) GetDeck(id int) (*models.Deck, error) {
+func (db *DB) GetDeck(userID, id int) (*models.Deck, error) {
 	var deck models.Deck
-	query := `SELECT id, name, created_at, updated_at FROM decks WHERE id = $1`
+	query := `
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id, name, 0 AS depth FROM decks WHERE id = $1
+			UNION ALL
+			SELECT d.id, d.parent_id, d.name, a.depth + 1
+			FROM decks d JOIN ancestors a ON d.id = a.parent_id
+		)
+		SELECT d.id, d.user_id, deck_role(d.id, $2) AS role, d.parent_id, d.name, d.kind, d.filter, d.preset_id, d.options, p.options AS preset_options, d.created_at, d.updated_at,
+			(SELECT string_agg(name, '::' ORDER BY depth DESC) FROM ancestors) AS path
+		FROM decks d
+		LEFT JOIN presets p ON p.id = d.preset_id
+		WHERE d.id = $1 AND deck_role(d.id, $2) IS NOT NULL AND d.deleted_at IS NULL`
 	
-	err := db.Get(&deck, query, id)
+	err := db.Get(&deck, query, id, userID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("deck not found")
@@ -33,9 +79,14 @@ func (db *DB) GetDeck(id int) (*models.Deck, error) {
 		return nil, fmt.Errorf("failed to get deck: %w", err)
 	}
 
-	cardsQuery := `SELECT id, deck_id, front, back, created_at, updated_at FROM cards WHERE deck_id = $1`
+	deck.ResolveOptions()
+
+	cardsQuery := `
+		SELECT c.id, c.deck_id, c.front, c.back, c.format, c.front_html, c.back_html, ` + cardSuspendedColumn("$2") + `, c.created_at, c.updated_at,
+		` + cardTagsColumn + `
+		FROM cards c WHERE c.deck_id = $1 AND c.deleted_at IS NULL`
 	var cards []models.Card
-	err = db.Select(&cards, cardsQuery, id)
+	err = db.Select(&cards, cardsQuery, id, userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get cards for deck: %w", err)
 	}
@@ -45,49 +96,186 @@ func (db *DB) GetDeck(id int) (*models.Deck, error) {
 	return &deck, nil
 }
 
-func (db *DB) GetAllDecks() ([]models.Deck, error) {
+// GetAllDecks returns the deck hierarchy of a user, including the decks shared
+// with the user. Top level decks and shared decks are returned with their
+// subdecks nested as children, and the total counts of every deck include
+// the cards of all its subdecks.
+func (db *DB) GetAllDecks(userID int) ([]models.Deck, error) {
 	var decks []models.Deck
 	query := `
 		SELECT 
 			d.id, 
+			d.user_id,
+			deck_role(d.id, $1) AS role,
+			d.parent_id,
 			d.name, 
+			d.kind,
+			d.filter,
+			d.preset_id,
+			d.options,
+			p.options AS preset_options,
 			d.created_at, 
 			d.updated_at,
-			COUNT(c.id) as card_count
+			COUNT(c.id) as card_count,
+			COUNT(c.id) FILTER (WHERE r.next_review_at IS NULL OR r.next_review_at <= NOW()) as due_count
 		FROM decks d
-		LEFT JOIN cards c ON d.id = c.deck_id
-		GROUP BY d.id
+		LEFT JOIN cards c ON d.id = c.deck_id AND c.deleted_at IS NULL
+		LEFT JOIN (` + latestReviews("$1") + `) r ON c.id = r.card_id
+		LEFT JOIN presets p ON p.id = d.preset_id
+		WHERE d.id IN (SELECT user_decks($1, 'viewer')) AND d.deleted_at IS NULL
+		GROUP BY d.id, p.id
 		ORDER BY d.created_at DESC`
 	
-	err := db.Select(&decks, query)
+	err := db.Select(&decks, query, userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get decks: %w", err)
 	}
 
-	return decks, nil
+	return buildDeckTree(decks), nil
+}
+
+// buildDeckTree nests decks under their parents, filling in paths and total
+// counts. Decks whose parent is not in the list become roots.
+func buildDeckTree(decks []models.Deck) []models.Deck {
+	present := make(map[int]bool)
+	for _, deck := range decks {
+		present[deck.ID] = true
+	}
+
+	children := make(map[int][]int)
+	var roots []int
+	for i, deck := range decks {
+		if deck.ParentID != nil && present[*deck.ParentID] {
+			children[*deck.ParentID] = append(children[*deck.ParentID], i)
+		} else {
+			roots = append(roots, i)
+		}
+	}
+
+	var build func(i int, parentPath string) models.Deck
+	build = func(i int, parentPath string) models.Deck {
+		deck := decks[i]
+		deck.ResolveOptions()
+		deck.Path = deck.Name
+		if parentPath != "" {
+			deck.Path = parentPath + "::" + deck.Name
+		}
+		deck.TotalCardCount = deck.CardCount
+		deck.TotalDueCount = deck.DueCount
+
+		for _, j := range children[deck.ID] {
+			child := build(j, deck.Path)
+			deck.TotalCardCount += child.TotalCardCount
+			deck.TotalDueCount += child.TotalDueCount
+			deck.Children = append(deck.Children, child)
+		}
+		return deck
+	}
+
+	tree := []models.Deck{}
+	for _, i := range roots {
+		tree = append(tree, build(i, ""))
+	}
+	return tree
 }
 
+// UpdateDeck stores the name, parent, options and filter of a deck on which
+// deck.UserID is an owner. It returns ErrDeckCycle when the new parent is the
+// deck itself or one of its subdecks.
 func (db *DB) UpdateDeck(deck *models.Deck) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if deck.ParentID != nil {
+		// Subdecks always belong to the owner of their parent
+		var exists bool
+		parentQuery := `
+			SELECT EXISTS (
+				SELECT 1 FROM decks p JOIN decks d ON d.user_id = p.user_id
+				WHERE p.id = $1 AND d.id = $2 AND p.deleted_at IS NULL
+			)`
+		if err := tx.Get(&exists, parentQuery, *deck.ParentID, deck.ID); err != nil {
+			return fmt.Errorf("failed to get parent deck: %w", err)
+		}
+		if !exists {
+			return fmt.Errorf("parent deck not found")
+		}
+
+		var cycle bool
+		cycleQuery := deckSubtreeCTE(models.DeckRoleOwner) + `
+			SELECT EXISTS (SELECT 1 FROM subtree WHERE id = $3)`
+
+		if err := tx.Get(&cycle, cycleQuery, deck.ID, deck.UserID, *deck.ParentID); err != nil {
+			return fmt.Errorf("failed to check deck hierarchy: %w", err)
+		}
+		if cycle {
+			return ErrDeckCycle
+		}
+	}
+
 	query := `
 		UPDATE decks 
-		SET name = $1, updated_at = NOW()
-		WHERE id = $2
+		SET name = $1, parent_id = $2, preset_id = $3, options = $4, filter = $5, updated_at = NOW()
+		WHERE id = $6 AND id IN (SELECT user_decks($7, 'owner')) AND deleted_at IS NULL
 		RETURNING updated_at`
 
-	err := db.QueryRow(query, deck.Name, deck.ID).Scan(&deck.UpdatedAt)
+	err = tx.QueryRow(query, deck.Name, deck.ParentID, deck.PresetID, deck.Options, deck.Filter, deck.ID, deck.UserID).Scan(&deck.UpdatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return fmt.Errorf("deck not found")
 		}
 		return fmt.Errorf("failed to update deck: %w", err)
 	}
+
+	// Moving the deck under a shared parent shares it too
+	if err := returnSharedCards(tx, deck.ID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
 	return nil
 }
 
-func (db *DB) DeleteDeck(id int) error {
-	query := `DELETE FROM decks WHERE id = $1`
-	
-	result, err := db.Exec(query, id)
+// DeleteDeck moves a deck with all its subdecks and their cards to the
+// trash. NOW() is fixed for the transaction, so everything shares the
+// deletion time of the deck and restoring the deck brings back exactly what
+// was trashed along with it.
+func (db *DB) DeleteDeck(userID, id int) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Cards borrowed by filtered decks go home first, so they are trashed
+	// with their home deck and not with the filtered deck
+	homeQuery := deckSubtreeCTE(models.DeckRoleOwner) + `
+		UPDATE cards SET deck_id = original_deck_id, original_deck_id = NULL
+		WHERE original_deck_id IS NOT NULL
+			AND (deck_id IN (SELECT id FROM subtree) OR original_deck_id IN (SELECT id FROM subtree))`
+
+	if _, err := tx.Exec(homeQuery, id, userID); err != nil {
+		return fmt.Errorf("failed to return borrowed cards: %w", err)
+	}
+
+	cardsQuery := deckSubtreeCTE(models.DeckRoleOwner) + `
+		UPDATE cards SET deleted_at = NOW()
+		WHERE deck_id IN (SELECT id FROM subtree) AND deleted_at IS NULL`
+
+	if _, err := tx.Exec(cardsQuery, id, userID); err != nil {
+		return fmt.Errorf("failed to delete deck cards: %w", err)
+	}
+
+	query := deckSubtreeCTE(models.DeckRoleOwner) + `
+		UPDATE decks SET deleted_at = NOW()
+		WHERE id IN (SELECT id FROM subtree)`
+
+	result, err := tx.Exec(query, id, userID)
 	if err != nil {
 		return fmt.Errorf("failed to delete deck: %w", err)
 	}
@@ -101,5 +289,8 @@ func (db *DB) DeleteDeck(id int) error {
 		return fmt.Errorf("deck not found")
 	}
 
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
 	return nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,123 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/dmltdev/flashcards/internal/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// FindDuplicateCard returns the oldest card in a deck the user can view whose
+// normalized front equals the given one, or nil if there is none.
+func (db *DB) FindDuplicateCard(userID, deckID int, frontNormalized string) (*models.Card, error) {
+	duplicates, err := db.FindDuplicateCards(userID, deckID, []string{frontNormalized})
+	if err != nil {
+		return nil, err
+	}
+	return duplicates[frontNormalized], nil
+}
+
+// FindDuplicateCards looks up many normalized fronts at once, for bulk
+// imports. It maps each front that already exists in a deck the user can
+// view to the oldest card carrying it.
+func (db *DB) FindDuplicateCards(userID, deckID int, frontsNormalized []string) (map[string]*models.Card, error) {
+	var cards []models.Card
+	query := `
+		SELECT DISTINCT ON (c.front_normalized)
+			c.id, c.deck_id, c.front, c.back, c.format, c.front_html, c.back_html, c.front_normalized, c.created_at, c.updated_at,
+		` + cardTagsColumn + `
+		FROM cards c
+		WHERE COALESCE(c.original_deck_id, c.deck_id) = $1 AND c.front_normalized = ANY($2) AND c.deleted_at IS NULL
+			AND $1 IN (SELECT user_decks($3, 'viewer'))
+		ORDER BY c.front_normalized, c.created_at, c.id`
+
+	if err := db.Select(&cards, query, deckID, pq.Array(frontsNormalized), userID); err != nil {
+		return nil, fmt.Errorf("failed to find duplicate cards: %w", err)
+	}
+
+	duplicates := make(map[string]*models.Card, len(cards))
+	for i := range cards {
+		duplicates[cards[i].FrontNormalized] = &cards[i]
+	}
+	return duplicates, nil
+}
+
+// MergeCard folds an incoming duplicate into an existing card: the content
+// of the incoming card replaces the existing one and the tags are combined.
+// The existing card keeps its ID and review history.
+func (db *DB) MergeCard(userID, existingID int, incoming *models.Card) (*models.Card, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := mergeCard(tx, userID, existingID, incoming); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return db.GetCard(userID, existingID)
+}
+
+func mergeCard(tx *sqlx.Tx, userID, existingID int, incoming *models.Card) error {
+	merged := *incoming
+	merged.ID = existingID
+	if err := updateCard(tx, userID, &merged); err != nil {
+		return err
+	}
+
+	_, err := addTags(tx, []int{existingID}, incoming.Tags)
+	return err
+}
+
+// GetDuplicateGroups returns the groups of cards in a deck the user can view
+// that share the same normalized front. Cards in a group are ordered oldest
+// first.
+func (db *DB) GetDuplicateGroups(userID, deckID int) ([]models.DuplicateGroup, error) {
+	var fronts []string
+	query := `
+		SELECT c.front_normalized
+		FROM cards c
+		WHERE COALESCE(c.original_deck_id, c.deck_id) = $1 AND c.deleted_at IS NULL
+			AND $1 IN (SELECT user_decks($2, 'viewer'))
+		GROUP BY c.front_normalized
+		HAVING COUNT(*) > 1
+		ORDER BY c.front_normalized`
+
+	if err := db.Select(&fronts, query, deckID, userID); err != nil {
+		return nil, fmt.Errorf("failed to get duplicate groups: %w", err)
+	}
+
+	groups := []models.DuplicateGroup{}
+	if len(fronts) == 0 {
+		return groups, nil
+	}
+
+	var cards []models.Card
+	cardsQuery := `
+		SELECT c.id, c.deck_id, c.front, c.back, c.format, c.front_html, c.back_html, c.front_normalized, c.created_at, c.updated_at,
+		` + cardTagsColumn + `
+		FROM cards c
+		WHERE COALESCE(c.original_deck_id, c.deck_id) = $1 AND c.front_normalized = ANY($2) AND c.deleted_at IS NULL
+			AND $1 IN (SELECT user_decks($3, 'viewer'))
+		ORDER BY c.front_normalized, c.created_at, c.id`
+
+	if err := db.Select(&cards, cardsQuery, deckID, pq.Array(fronts), userID); err != nil {
+		return nil, fmt.Errorf("failed to get duplicate cards: %w", err)
+	}
+
+	for _, card := range cards {
+		if len(groups) == 0 || groups[len(groups)-1].NormalizedFront != card.FrontNormalized {
+			groups = append(groups, models.DuplicateGroup{NormalizedFront: card.FrontNormalized})
+		}
+		last := &groups[len(groups)-1]
+		last.Cards = append(last.Cards, card)
+	}
+
+	return groups, nil
+}
@@ -0,0 +1,194 @@
+package database
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/dmltdev/flashcards/internal/models"
+	"github.com/dmltdev/flashcards/internal/tagexpr"
+	"github.com/jmoiron/sqlx"
+)
+
+// RebuildFilteredDeck returns the cards of a filtered deck to their home
+// decks and pulls in the cards currently matching its filter. It returns the
+// number of cards pulled in.
+func (db *DB) RebuildFilteredDeck(userID, id int) (int64, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var filter models.DeckFilter
+	query := `SELECT filter FROM decks WHERE id = $1 AND user_id = $2 AND kind = 'filtered' AND deleted_at IS NULL FOR UPDATE`
+	if err := tx.Get(&filter, query, id, userID); err != nil {
+		return 0, fmt.Errorf("filtered deck not found: %w", err)
+	}
+
+	if _, err := emptyFilteredDeck(tx, id); err != nil {
+		return 0, err
+	}
+
+	condition, args, err := deckFilterSQL(&filter, []any{id, userID})
+	if err != nil {
+		return 0, err
+	}
+
+	limit := filter.Limit
+	if limit == 0 {
+		limit = models.DefaultFilterLimit
+	}
+	args = append(args, limit)
+
+	// Cards already borrowed by another filtered deck stay where they are,
+	// and cards of shared decks stay visible to the other users
+	pullQuery := `
+		UPDATE cards
+		SET original_deck_id = deck_id, deck_id = $1
+		WHERE id IN (
+			SELECT c.id
+			FROM cards c
+			JOIN decks d ON d.id = c.deck_id AND d.user_id = $2 AND d.kind = 'normal' AND d.deleted_at IS NULL
+				AND NOT deck_is_shared(d.id)
+			LEFT JOIN (` + latestReviews("$2") + `) r ON c.id = r.card_id
+			WHERE c.deleted_at IS NULL
+				AND NOT EXISTS (SELECT 1 FROM card_suspensions cs WHERE cs.card_id = c.id AND cs.user_id = $2)
+				AND c.original_deck_id IS NULL
+				AND ` + condition + `
+			ORDER BY r.next_review_at ASC NULLS LAST, c.id
+			LIMIT $` + strconv.Itoa(len(args)) + `
+		)`
+
+	result, err := tx.Exec(pullQuery, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to build filtered deck: %w", err)
+	}
+
+	pulled, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return pulled, nil
+}
+
+// EmptyFilteredDeck returns all cards of a filtered deck to their home decks
+// and returns how many cards were returned.
+func (db *DB) EmptyFilteredDeck(userID, id int) (int64, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	query := `SELECT EXISTS (SELECT 1 FROM decks WHERE id = $1 AND user_id = $2 AND kind = 'filtered' AND deleted_at IS NULL)`
+	if err := tx.Get(&exists, query, id, userID); err != nil {
+		return 0, fmt.Errorf("failed to get deck: %w", err)
+	}
+	if !exists {
+		return 0, fmt.Errorf("filtered deck not found")
+	}
+
+	returned, err := emptyFilteredDeck(tx, id)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return returned, nil
+}
+
+func emptyFilteredDeck(tx *sqlx.Tx, id int) (int64, error) {
+	query := `
+		UPDATE cards
+		SET deck_id = original_deck_id, original_deck_id = NULL
+		WHERE deck_id = $1 AND original_deck_id IS NOT NULL`
+
+	result, err := tx.Exec(query, id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to empty filtered deck: %w", err)
+	}
+
+	returned, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return returned, nil
+}
+
+// returnSharedCards returns the cards filtered decks borrowed from the deck
+// and its subdecks to their home decks once the deck is shared, since the
+// other users cannot see the filtered decks holding them.
+func returnSharedCards(tx *sqlx.Tx, deckID int) error {
+	query := `
+		WITH RECURSIVE subtree AS (
+			SELECT id FROM decks WHERE id = $1
+			UNION ALL
+			SELECT d.id FROM decks d JOIN subtree s ON d.parent_id = s.id
+		)
+		UPDATE cards
+		SET deck_id = original_deck_id, original_deck_id = NULL
+		WHERE original_deck_id IN (SELECT id FROM subtree) AND deck_is_shared($1)`
+
+	if _, err := tx.Exec(query, deckID); err != nil {
+		return fmt.Errorf("failed to return shared cards: %w", err)
+	}
+	return nil
+}
+
+// deckFilterSQL translates a deck filter into a boolean SQL condition on the
+// card aliased as c and its latest review aliased as r, both of the user
+// bound to $2.
+func deckFilterSQL(filter *models.DeckFilter, args []any) (string, []any, error) {
+	condition := "TRUE"
+
+	if filter.Tags != "" {
+		expr, err := tagexpr.Parse(filter.Tags)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid tags filter: %w", err)
+		}
+		var tagCondition string
+		tagCondition, args = tagFilterSQL(expr, args)
+		condition += " AND " + tagCondition
+	}
+
+	switch filter.Due {
+	case models.DueStateDue:
+		condition += " AND r.next_review_at <= NOW()"
+	case models.DueStateNew:
+		condition += " AND r.card_id IS NULL"
+	case models.DueStateDueOrNew:
+		condition += " AND (r.card_id IS NULL OR r.next_review_at <= NOW())"
+	}
+
+	// A lapse is a review where the card was forgotten
+	if filter.MinLapses > 0 {
+		args = append(args, filter.MinLapses)
+		condition += ` AND (SELECT COUNT(*) FROM reviews lr WHERE lr.card_id = c.id AND lr.user_id = $2 AND lr.quality < 3) >= $` + strconv.Itoa(len(args))
+	}
+
+	if filter.DeckID != nil {
+		args = append(args, *filter.DeckID)
+		condition += ` AND c.deck_id IN (
+			WITH RECURSIVE filter_subtree AS (
+				SELECT id FROM decks WHERE id = $` + strconv.Itoa(len(args)) + `
+				UNION ALL
+				SELECT fd.id FROM decks fd JOIN filter_subtree fs ON fd.parent_id = fs.id
+			)
+			SELECT id FROM filter_subtree
+		)`
+	}
+
+	if filter.AddedWithinDays > 0 {
+		args = append(args, filter.AddedWithinDays)
+		condition += ` AND c.created_at >= NOW() - make_interval(days => $` + strconv.Itoa(len(args)) + `)`
+	}
+
+	return condition, args, nil
+}
@@ -0,0 +1,139 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/dmltdev/flashcards/internal/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// mediaLockKey identifies the advisory lock that keeps the garbage collector
+// from deleting a file while an upload of the same content is recorded.
+// Uploads hold it shared, the garbage collector exclusively.
+const mediaLockKey = 270001
+
+// CreateMedia stores the content of the upload with put, which fills in the
+// checksum and size of the media, and records it.
+func (db *DB) CreateMedia(media *models.Media, put func() error) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock_shared($1)`, mediaLockKey); err != nil {
+		return fmt.Errorf("failed to lock media: %w", err)
+	}
+
+	if err := put(); err != nil {
+		return fmt.Errorf("failed to store media: %w", err)
+	}
+
+	// Uploading the same content twice returns the existing record
+	query := `
+		INSERT INTO media (checksum, mime_type, size, filename, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, NOW(), NOW())
+		ON CONFLICT (checksum) DO UPDATE SET updated_at = NOW()
+		RETURNING id, mime_type, filename, created_at, updated_at`
+
+	err = tx.QueryRow(query, media.Checksum, media.MimeType, media.Size, media.Filename).Scan(
+		&media.ID, &media.MimeType, &media.Filename, &media.CreatedAt, &media.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create media: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) GetMediaByChecksum(checksum string) (*models.Media, error) {
+	var media models.Media
+	query := `SELECT id, checksum, mime_type, size, filename, created_at, updated_at FROM media WHERE checksum = $1`
+
+	err := db.Get(&media, query, checksum)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("media not found")
+		}
+		return nil, fmt.Errorf("failed to get media: %w", err)
+	}
+	return &media, nil
+}
+
+// DeleteUnreferencedMedia removes media records that no card references and
+// that are older than the grace period, so fresh uploads are not collected
+// before a card has had the chance to reference them. remove is called with
+// the checksum of every deleted record to delete its file once the deletion
+// is committed, so a failed commit never leaves records without files. The
+// media lock is held until then, so no upload can record the same content
+// in between. It returns the checksums of the deleted records.
+func (db *DB) DeleteUnreferencedMedia(gracePeriod time.Duration, remove func(checksum string)) ([]string, error) {
+	ctx := context.Background()
+	conn, err := db.Connx(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	// A session lock outlives the transaction, unlike the shared
+	// transaction locks taken by uploads
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, mediaLockKey); err != nil {
+		return nil, fmt.Errorf("failed to lock media: %w", err)
+	}
+	defer conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, mediaLockKey)
+
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var checksums []string
+	query := `
+		DELETE FROM media m
+		WHERE m.updated_at < $1
+			AND NOT EXISTS (SELECT 1 FROM card_media cm WHERE cm.media_id = m.id)
+		RETURNING m.checksum`
+
+	err = tx.Select(&checksums, query, time.Now().Add(-gracePeriod))
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete unreferenced media: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	for _, checksum := range checksums {
+		remove(checksum)
+	}
+	return checksums, nil
+}
+
+// linkCardMedia replaces the media references of a card with the media
+// referenced from its content. Unknown checksums are ignored.
+func linkCardMedia(tx *sqlx.Tx, card *models.Card) error {
+	if _, err := tx.Exec(`DELETE FROM card_media WHERE card_id = $1`, card.ID); err != nil {
+		return fmt.Errorf("failed to unlink card media: %w", err)
+	}
+
+	checksums := card.MediaReferences()
+	if len(checksums) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO card_media (card_id, media_id)
+		SELECT $1, id FROM media WHERE checksum = ANY($2)`
+
+	if _, err := tx.Exec(query, card.ID, pq.Array(checksums)); err != nil {
+		return fmt.Errorf("failed to link card media: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dmltdev/flashcards/internal/models"
+)
+
+var ErrInvalidOIDCLogin = errors.New("unknown or expired login")
+
+// CreateOIDCLogin stores the nonce and PKCE verifier of a login started with
+// the OIDC provider under the hash of its state, and drops expired logins.
+func (db *DB) CreateOIDCLogin(stateHash, nonce, verifier string, expiresAt time.Time) error {
+	if _, err := db.Exec(`DELETE FROM oidc_logins WHERE expires_at < NOW()`); err != nil {
+		return fmt.Errorf("failed to delete expired logins: %w", err)
+	}
+
+	query := `
+		INSERT INTO oidc_logins (state_hash, nonce, verifier, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, NOW())`
+
+	if _, err := db.Exec(query, stateHash, nonce, verifier, expiresAt); err != nil {
+		return fmt.Errorf("failed to create login: %w", err)
+	}
+	return nil
+}
+
+// ConsumeOIDCLogin removes the login with the given state hash and returns
+// its nonce and PKCE verifier, so each login can complete only once.
+func (db *DB) ConsumeOIDCLogin(stateHash string) (nonce, verifier string, err error) {
+	query := `
+		DELETE FROM oidc_logins
+		WHERE state_hash = $1
+		RETURNING nonce, verifier, expires_at`
+
+	var expiresAt time.Time
+	err = db.QueryRow(query, stateHash).Scan(&nonce, &verifier, &expiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", "", ErrInvalidOIDCLogin
+		}
+		return "", "", fmt.Errorf("failed to consume login: %w", err)
+	}
+	if !expiresAt.After(time.Now()) {
+		return "", "", ErrInvalidOIDCLogin
+	}
+	return nonce, verifier, nil
+}
+
+// ProvisionOIDCUser returns the user linked to an OIDC identity. An identity
+// seen for the first time is linked to the existing user with the same email
+// if the provider verified the email and the user has no password, or to a
+// new user without a password if no user has that email. Emails of password
+// accounts are never verified, so whoever controls the email at the provider
+// could otherwise take over the account.
+func (db *DB) ProvisionOIDCUser(identity *models.OIDCIdentity) (*models.User, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var user models.User
+	query := `
+		SELECT u.id, u.email, u.name, u.created_at, u.updated_at
+		FROM users u
+		JOIN user_identities i ON i.user_id = u.id
+		WHERE i.issuer = $1 AND i.subject = $2`
+
+	err = tx.Get(&user, query, identity.Issuer, identity.Subject)
+	if err == nil {
+		return &user, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get user by identity: %w", err)
+	}
+
+	user = models.User{Email: identity.Email, Name: identity.Name}
+	user.Normalize()
+	if err := user.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid identity: %w", err)
+	}
+
+	existingQuery := `
+		SELECT id, email, name, created_at, updated_at, COALESCE(password_hash, '') <> ''
+		FROM users WHERE email = $1`
+
+	var hasPassword bool
+	err = tx.QueryRow(existingQuery, user.Email).Scan(&user.ID, &user.Email, &user.Name, &user.CreatedAt, &user.UpdatedAt, &hasPassword)
+	switch {
+	case err == nil && (hasPassword || !identity.EmailVerified):
+		return nil, ErrEmailTaken
+	case err == sql.ErrNoRows:
+		insertQuery := `
+			INSERT INTO users (email, name, created_at, updated_at)
+			VALUES ($1, $2, NOW(), NOW())
+			RETURNING id, created_at, updated_at`
+
+		if err := tx.QueryRow(insertQuery, user.Email, user.Name).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt); err != nil {
+			if isUniqueViolation(err) {
+				return nil, ErrEmailTaken
+			}
+			return nil, fmt.Errorf("failed to create user: %w", err)
+		}
+	case err != nil:
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	linkQuery := `
+		INSERT INTO user_identities (user_id, issuer, subject, created_at)
+		VALUES ($1, $2, $3, NOW())`
+
+	if _, err := tx.Exec(linkQuery, user.ID, identity.Issuer, identity.Subject); err != nil {
+		return nil, fmt.Errorf("failed to link identity: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &user, nil
+}
@@ -0,0 +1,147 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/dmltdev/flashcards/internal/models"
+)
+
+func (db *DB) CreatePreset(preset *models.Preset) error {
+	query := `
+		INSERT INTO presets (user_id, name, options, created_at, updated_at)
+		VALUES ($1, $2, $3, NOW(), NOW())
+		RETURNING id, created_at, updated_at`
+
+	err := db.QueryRow(query, preset.UserID, preset.Name, preset.Options).Scan(
+		&preset.ID, &preset.CreatedAt, &preset.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create preset: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) GetPreset(userID, id int) (*models.Preset, error) {
+	var preset models.Preset
+	query := `
+		SELECT p.id, p.user_id, p.name, p.options, p.created_at, p.updated_at,
+			(SELECT COUNT(*) FROM decks d WHERE d.preset_id = p.id AND d.deleted_at IS NULL) AS deck_count
+		FROM presets p WHERE p.id = $1 AND p.user_id = $2`
+
+	err := db.Get(&preset, query, id, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("preset not found")
+		}
+		return nil, fmt.Errorf("failed to get preset: %w", err)
+	}
+	return &preset, nil
+}
+
+func (db *DB) GetAllPresets(userID int) ([]models.Preset, error) {
+	presets := []models.Preset{}
+	query := `
+		SELECT
+			p.id,
+			p.user_id,
+			p.name,
+			p.options,
+			p.created_at,
+			p.updated_at,
+			COUNT(d.id) AS deck_count
+		FROM presets p
+		LEFT JOIN decks d ON d.preset_id = p.id AND d.deleted_at IS NULL
+		WHERE p.user_id = $1
+		GROUP BY p.id
+		ORDER BY p.name`
+
+	err := db.Select(&presets, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get presets: %w", err)
+	}
+	return presets, nil
+}
+
+func (db *DB) UpdatePreset(preset *models.Preset) error {
+	query := `
+		UPDATE presets
+		SET name = $1, options = $2, updated_at = NOW()
+		WHERE id = $3 AND user_id = $4
+		RETURNING created_at, updated_at`
+
+	err := db.QueryRow(query, preset.Name, preset.Options, preset.ID, preset.UserID).Scan(&preset.CreatedAt, &preset.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("preset not found")
+		}
+		return fmt.Errorf("failed to update preset: %w", err)
+	}
+	return nil
+}
+
+// DeletePreset removes a preset. Decks using it fall back to the default
+// options plus their own overrides.
+func (db *DB) DeletePreset(userID, id int) error {
+	query := `DELETE FROM presets WHERE id = $1 AND user_id = $2`
+
+	result, err := db.Exec(query, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete preset: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("preset not found")
+	}
+
+	return nil
+}
+
+// GetDeckOptions returns the effective options of a deck the user can view.
+func (db *DB) GetDeckOptions(userID, deckID int) (*models.DeckOptions, error) {
+	var deck models.Deck
+	query := `
+		SELECT d.options, p.options AS preset_options
+		FROM decks d
+		LEFT JOIN presets p ON p.id = d.preset_id
+		WHERE d.id = $1 AND d.id IN (SELECT user_decks($2, 'viewer')) AND d.deleted_at IS NULL`
+
+	err := db.Get(&deck, query, deckID, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("deck not found")
+		}
+		return nil, fmt.Errorf("failed to get deck options: %w", err)
+	}
+
+	deck.ResolveOptions()
+	return &deck.EffectiveOptions, nil
+}
+
+// GetCardOptions returns the effective options of the home deck of a card
+// the user can view, which decide how the card is scheduled even while a
+// filtered deck borrows it.
+func (db *DB) GetCardOptions(userID, cardID int) (*models.DeckOptions, error) {
+	var deck models.Deck
+	query := `
+		SELECT d.options, p.options AS preset_options
+		FROM cards c
+		JOIN decks d ON d.id = COALESCE(c.original_deck_id, c.deck_id)
+		LEFT JOIN presets p ON p.id = d.preset_id
+		WHERE c.id = $1 AND c.deleted_at IS NULL AND c.deck_id IN (SELECT user_decks($2, 'viewer'))`
+
+	err := db.Get(&deck, query, cardID, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("card not found")
+		}
+		return nil, fmt.Errorf("failed to get card options: %w", err)
+	}
+
+	deck.ResolveOptions()
+	return &deck.EffectiveOptions, nil
+}
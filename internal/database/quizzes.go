@@ -0,0 +1,356 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+
+	"github.com/dmltdev/flashcards/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrNoQuizCards      = errors.New("the deck has no cards to quiz on")
+	ErrNotEnoughChoices = errors.New("multiple choice quizzes need cards with at least two different backs")
+	ErrQuizOver         = errors.New("the quiz is over")
+	ErrInvalidAnswer    = errors.New("invalid answer")
+)
+
+// maxChoicePool caps the backs loaded to draw the wrong choices of multiple
+// choice questions from.
+const maxChoicePool = 500
+
+// quizScoreSet sets the number of correct answers and the score of the
+// quizzes being updated.
+const quizScoreSet = `
+	correct = (SELECT COUNT(*) FROM quiz_questions q WHERE q.quiz_id = quizzes.id AND q.correct),
+	score = (SELECT COUNT(*) FROM quiz_questions q WHERE q.quiz_id = quizzes.id AND q.correct)::FLOAT8 / question_count`
+
+const quizColumns = `
+	z.id, z.user_id, z.deck_id, z.mode, z.status, z.question_count,
+	(SELECT COUNT(*) FROM quiz_questions q WHERE q.quiz_id = z.id AND q.answered_at IS NOT NULL) AS answered,
+	z.correct, z.score, z.time_limit_seconds, z.expires_at, z.submitted_at, z.created_at`
+
+// CreateQuiz generates a quiz on a random sample of the cards of a deck the
+// user can view and its subdecks. The quiz has fewer questions than asked
+// for when the deck has fewer cards. The time limit starts right away.
+func (db *DB) CreateQuiz(userID, deckID int, opts models.QuizOptions) (*models.Quiz, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var cards []struct {
+		ID        int    `db:"id"`
+		FrontHTML string `db:"front_html"`
+		Back      string `db:"back"`
+		Format    string `db:"format"`
+		BackHTML  string `db:"back_html"`
+	}
+	cardsQuery := deckSubtreeCTE(models.DeckRoleViewer) + `
+		SELECT c.id, c.front_html, c.back, c.format, c.back_html
+		FROM cards c
+		WHERE c.deck_id IN (SELECT id FROM subtree) AND c.deleted_at IS NULL
+		ORDER BY random()
+		LIMIT $3`
+
+	if err := tx.Select(&cards, cardsQuery, deckID, userID, opts.QuestionCount); err != nil {
+		return nil, fmt.Errorf("failed to get cards: %w", err)
+	}
+	if len(cards) == 0 {
+		return nil, ErrNoQuizCards
+	}
+
+	var pool []string
+	if opts.Mode == models.QuizModeMultipleChoice {
+		poolQuery := deckSubtreeCTE(models.DeckRoleViewer) + `
+			SELECT back_html FROM (
+				SELECT DISTINCT c.back_html
+				FROM cards c
+				WHERE c.deck_id IN (SELECT id FROM subtree) AND c.deleted_at IS NULL
+			) backs
+			ORDER BY random()
+			LIMIT $3`
+
+		if err := tx.Select(&pool, poolQuery, deckID, userID, maxChoicePool); err != nil {
+			return nil, fmt.Errorf("failed to get choices: %w", err)
+		}
+		if len(pool) < 2 {
+			return nil, ErrNotEnoughChoices
+		}
+	}
+
+	quiz := models.Quiz{
+		UserID:           userID,
+		DeckID:           deckID,
+		Mode:             opts.Mode,
+		QuestionCount:    len(cards),
+		TimeLimitSeconds: opts.TimeLimitSeconds,
+	}
+	query := `
+		INSERT INTO quizzes (user_id, deck_id, mode, question_count, time_limit_seconds, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, NOW() + $5 * INTERVAL '1 second', NOW())
+		RETURNING id, status, expires_at, created_at`
+
+	err = tx.QueryRow(query, userID, deckID, quiz.Mode, quiz.QuestionCount, quiz.TimeLimitSeconds).Scan(
+		&quiz.ID, &quiz.Status, &quiz.ExpiresAt, &quiz.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create quiz: %w", err)
+	}
+
+	questionQuery := `
+		INSERT INTO quiz_questions (quiz_id, position, card_id, front_html, answer, answer_format, answer_html, choices, correct_choice)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	for i, card := range cards {
+		question := models.QuizQuestion{
+			QuizID:       quiz.ID,
+			Position:     i + 1,
+			CardID:       &card.ID,
+			FrontHTML:    card.FrontHTML,
+			Answer:       card.Back,
+			AnswerFormat: card.Format,
+			AnswerHTML:   card.BackHTML,
+		}
+		if opts.Mode == models.QuizModeMultipleChoice {
+			question.Choices, question.CorrectChoice = quizChoices(card.BackHTML, pool)
+		}
+
+		_, err := tx.Exec(questionQuery, question.QuizID, question.Position, question.CardID, question.FrontHTML,
+			question.Answer, question.AnswerFormat, question.AnswerHTML, question.Choices, question.CorrectChoice)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create quiz question: %w", err)
+		}
+		quiz.Questions = append(quiz.Questions, question)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	quiz.Conceal()
+	return &quiz, nil
+}
+
+// quizChoices shuffles the correct answer among wrong answers drawn from the
+// pool and returns the choices with the index of the correct one.
+func quizChoices(answer string, pool []string) ([]string, *int) {
+	choices := []string{answer}
+	for _, i := range rand.Perm(len(pool)) {
+		if len(choices) == models.QuizChoicesPerQuestion {
+			break
+		}
+		if pool[i] != answer {
+			choices = append(choices, pool[i])
+		}
+	}
+
+	rand.Shuffle(len(choices), func(i, j int) {
+		choices[i], choices[j] = choices[j], choices[i]
+	})
+	for i, choice := range choices {
+		if choice == answer {
+			return choices, &i
+		}
+	}
+	return choices, nil
+}
+
+// expireQuizzes ends the quizzes of the user whose time limit ran out and
+// scores them with the answers given in time.
+func expireQuizzes(q sqlx.Execer, userID int) error {
+	query := `
+		UPDATE quizzes
+		SET status = 'timed_out', submitted_at = expires_at, ` + quizScoreSet + `
+		WHERE user_id = $1 AND status = 'in_progress' AND expires_at < NOW()`
+
+	if _, err := q.Exec(query, userID); err != nil {
+		return fmt.Errorf("failed to expire quizzes: %w", err)
+	}
+	return nil
+}
+
+// GetQuizzes lists the quizzes of the user, newest first, without their
+// questions.
+func (db *DB) GetQuizzes(userID int) ([]models.Quiz, error) {
+	if err := expireQuizzes(db, userID); err != nil {
+		return nil, err
+	}
+
+	quizzes := []models.Quiz{}
+	query := `
+		SELECT ` + quizColumns + `
+		FROM quizzes z
+		WHERE z.user_id = $1
+		ORDER BY z.created_at DESC, z.id DESC`
+
+	if err := db.Select(&quizzes, query, userID); err != nil {
+		return nil, fmt.Errorf("failed to get quizzes: %w", err)
+	}
+	return quizzes, nil
+}
+
+// GetQuiz returns a quiz of the user with its questions. The answers are
+// concealed while the quiz is in progress.
+func (db *DB) GetQuiz(userID, id int) (*models.Quiz, error) {
+	if err := expireQuizzes(db, userID); err != nil {
+		return nil, err
+	}
+
+	var quiz models.Quiz
+	query := `
+		SELECT ` + quizColumns + `
+		FROM quizzes z
+		WHERE z.id = $1 AND z.user_id = $2`
+
+	err := db.Get(&quiz, query, id, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("quiz not found")
+		}
+		return nil, fmt.Errorf("failed to get quiz: %w", err)
+	}
+
+	questionsQuery := `
+		SELECT quiz_id, position, card_id, front_html, answer, answer_format, answer_html, choices, correct_choice,
+			response, choice, correct, answered_at
+		FROM quiz_questions
+		WHERE quiz_id = $1
+		ORDER BY position`
+
+	if err := db.Select(&quiz.Questions, questionsQuery, id); err != nil {
+		return nil, fmt.Errorf("failed to get quiz questions: %w", err)
+	}
+
+	quiz.Conceal()
+	return &quiz, nil
+}
+
+// AnswerQuizQuestion grades and stores the answer to a question of a quiz
+// of the user that is in progress. A question can be answered again until
+// the quiz is over. Answers do not affect the scheduling of the card.
+func (db *DB) AnswerQuizQuestion(userID, quizID, position int, answer models.QuizAnswer) (*models.QuizQuestion, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockQuiz(tx, userID, quizID); err != nil {
+		if errors.Is(err, ErrQuizOver) {
+			// The quiz may just have timed out
+			if err := tx.Commit(); err != nil {
+				return nil, fmt.Errorf("failed to commit transaction: %w", err)
+			}
+		}
+		return nil, err
+	}
+
+	var question models.QuizQuestion
+	query := `
+		SELECT quiz_id, position, card_id, front_html, answer, answer_format, answer_html, choices, correct_choice,
+			response, choice, correct, answered_at
+		FROM quiz_questions
+		WHERE quiz_id = $1 AND position = $2`
+
+	if err := tx.Get(&question, query, quizID, position); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("quiz question not found")
+		}
+		return nil, fmt.Errorf("failed to get quiz question: %w", err)
+	}
+
+	if err := question.Grade(answer); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAnswer, err)
+	}
+
+	updateQuery := `
+		UPDATE quiz_questions
+		SET response = $1, choice = $2, correct = $3, answered_at = NOW()
+		WHERE quiz_id = $4 AND position = $5
+		RETURNING answered_at`
+
+	err = tx.QueryRow(updateQuery, question.Response, question.Choice, question.Correct, quizID, position).Scan(&question.AnsweredAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to answer quiz question: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	// The grading is only revealed with the results of the quiz
+	question.Answer = ""
+	question.AnswerHTML = ""
+	question.CorrectChoice = nil
+	question.Correct = nil
+	return &question, nil
+}
+
+// SubmitQuiz ends a quiz of the user that is in progress and stores its
+// score. Unanswered questions count as wrong.
+func (db *DB) SubmitQuiz(userID, id int) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockQuiz(tx, userID, id); err != nil {
+		if errors.Is(err, ErrQuizOver) {
+			if err := tx.Commit(); err != nil {
+				return fmt.Errorf("failed to commit transaction: %w", err)
+			}
+		}
+		return err
+	}
+
+	query := `
+		UPDATE quizzes
+		SET status = 'completed', submitted_at = NOW(), ` + quizScoreSet + `
+		WHERE id = $1`
+
+	if _, err := tx.Exec(query, id); err != nil {
+		return fmt.Errorf("failed to submit quiz: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// lockQuiz locks a quiz of the user that is in progress. It returns
+// ErrQuizOver when the quiz was submitted or its time ran out, in which case
+// it is scored as timed out.
+func lockQuiz(tx *sqlx.Tx, userID, id int) error {
+	var quiz struct {
+		Status  string `db:"status"`
+		Expired bool   `db:"expired"`
+	}
+	query := `
+		SELECT status, COALESCE(expires_at < NOW(), FALSE) AS expired
+		FROM quizzes
+		WHERE id = $1 AND user_id = $2
+		FOR UPDATE`
+
+	if err := tx.Get(&quiz, query, id, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("quiz not found")
+		}
+		return fmt.Errorf("failed to get quiz: %w", err)
+	}
+
+	if quiz.Status != models.QuizStatusInProgress {
+		return ErrQuizOver
+	}
+	if quiz.Expired {
+		if err := expireQuizzes(tx, userID); err != nil {
+			return err
+		}
+		return ErrQuizOver
+	}
+	return nil
+}
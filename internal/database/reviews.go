@@ -1,31 +1,47 @@
 package database
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 
 	"github.com/dmltdev/flashcards/internal/models"
 )
 
-func (db *DB) CreateReview(review *models.Review) error {
+var ErrCardNotFound = errors.New("card not found")
+
+// CreateReview records a review by the user of a card they can view. Every
+// collaborator on a shared deck keeps their own review history.
+func (db *DB) CreateReview(userID int, review *models.Review) error {
 	query := `
-		INSERT INTO reviews (card_id, quality, reviewed_at, next_review_at, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, NOW(), NOW())
+		INSERT INTO reviews (card_id, user_id, quality, reviewed_at, next_review_at, duration_ms, created_at, updated_at)
+		SELECT $1, $5, $2, $3, $4, $6, NOW(), NOW()
+		WHERE EXISTS (
+			SELECT 1 FROM cards
+			WHERE id = $1 AND deleted_at IS NULL
+				AND deck_id IN (SELECT user_decks($5, 'viewer'))
+		)
 		RETURNING id, created_at, updated_at`
 
-	err := db.QueryRow(query, review.CardID, review.Quality, review.ReviewedAt, review.NextReviewAt).Scan(
+	err := db.QueryRow(query, review.CardID, review.Quality, review.ReviewedAt, review.NextReviewAt, userID, review.DurationMs).Scan(
 		&review.ID, &review.CreatedAt, &review.UpdatedAt)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrCardNotFound
+		}
 		return fmt.Errorf("failed to create review: %w", err)
 	}
 	return nil
 }
 
-func (db *DB) GetReviewsByCard(cardID int) ([]models.Review, error) {
+func (db *DB) GetReviewsByCard(userID, cardID int) ([]models.Review, error) {
 	var reviews []models.Review
-	query := `SELECT id, card_id, quality, reviewed_at, next_review_at, created_at, updated_at 
-			  FROM reviews WHERE card_id = $1 ORDER BY reviewed_at DESC`
+	query := `SELECT r.id, r.card_id, r.user_id, r.quality, r.reviewed_at, r.next_review_at, r.duration_ms, r.created_at, r.updated_at 
+			  FROM reviews r
+			  JOIN cards c ON c.id = r.card_id AND c.deck_id IN (SELECT user_decks($2, 'viewer'))
+			  WHERE r.card_id = $1 AND r.user_id = $2 ORDER BY r.reviewed_at DESC`
 	
-	err := db.Select(&reviews, query, cardID)
+	err := db.Select(&reviews, query, cardID, userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get reviews by card: %w", err)
 	}
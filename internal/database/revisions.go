@@ -0,0 +1,76 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/dmltdev/flashcards/internal/diff"
+	"github.com/dmltdev/flashcards/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// saveCardRevision archives the stored content of a card before it is
+// replaced by the content of the given card. Nothing is archived when the
+// content does not change.
+func saveCardRevision(tx *sqlx.Tx, card *models.Card) error {
+	query := `
+		INSERT INTO card_revisions (card_id, front, back, format, author, created_at, archived_at)
+		SELECT id, front, back, format, updated_by, updated_at, NOW()
+		FROM cards
+		WHERE id = $1 AND (front, back, format) IS DISTINCT FROM ($2, $3, $4)`
+
+	_, err := tx.Exec(query, card.ID, card.Front, card.Back, card.Format)
+	if err != nil {
+		return fmt.Errorf("failed to save card revision: %w", err)
+	}
+	return nil
+}
+
+// GetCardHistory returns all versions of a card, newest first, starting with
+// the current one. Each version carries the diff from the version before it.
+func (db *DB) GetCardHistory(userID, cardID int) ([]models.CardRevision, error) {
+	var revisions []models.CardRevision
+	query := `
+		SELECT 0 AS id, id AS card_id, front, back, format, updated_by AS author, TRUE AS current, updated_at AS created_at
+		FROM cards
+		WHERE id = $1 AND deleted_at IS NULL
+			AND deck_id IN (SELECT user_decks($2, 'viewer'))
+		UNION ALL
+		SELECT id, card_id, front, back, format, author, FALSE AS current, created_at
+		FROM card_revisions WHERE card_id = $1
+		ORDER BY current DESC, id DESC`
+
+	err := db.Select(&revisions, query, cardID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get card history: %w", err)
+	}
+	if len(revisions) == 0 || !revisions[0].Current {
+		return nil, fmt.Errorf("card not found")
+	}
+
+	for i := 0; i < len(revisions)-1; i++ {
+		previous := revisions[i+1]
+		revisions[i].FrontDiff = diff.Words(previous.Front, revisions[i].Front)
+		revisions[i].BackDiff = diff.Words(previous.Back, revisions[i].Back)
+	}
+
+	return revisions, nil
+}
+
+func (db *DB) GetCardRevision(userID, cardID, revisionID int) (*models.CardRevision, error) {
+	var revision models.CardRevision
+	query := `
+		SELECT r.id, r.card_id, r.front, r.back, r.format, r.author, r.created_at
+		FROM card_revisions r
+		JOIN cards c ON c.id = r.card_id AND c.deck_id IN (SELECT user_decks($3, 'viewer'))
+		WHERE r.id = $1 AND r.card_id = $2`
+
+	err := db.Get(&revision, query, revisionID, cardID, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("card revision not found")
+		}
+		return nil, fmt.Errorf("failed to get card revision: %w", err)
+	}
+	return &revision, nil
+}
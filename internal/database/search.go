@@ -0,0 +1,80 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/dmltdev/flashcards/internal/content"
+	"github.com/dmltdev/flashcards/internal/models"
+	"github.com/lib/pq"
+)
+
+// The highlight markers look like tags so they cannot clash with card text,
+// whose tags are stripped before the snippets are built.
+const (
+	highlightStart = "<hl>"
+	highlightStop  = "</hl>"
+)
+
+// plainText strips the tags from the rendered card HTML in the column, so
+// snippets show the text without markup.
+func plainText(column string) string {
+	return `regexp_replace(` + column + `, '<[^>]*>', ' ', 'g')`
+}
+
+// SearchCards runs a full-text search over the front, back and tags of the
+// cards a user can view, optionally restricted to the given decks. The query accepts web
+// search syntax: quoted phrases, OR and -excluded terms.
+func (db *DB) SearchCards(userID int, q string, deckIDs []int, limit, offset int) (*models.SearchResults, error) {
+	filter := "AND c.deck_id IN (SELECT user_decks($2, 'viewer'))"
+	args := []any{q, userID}
+	if len(deckIDs) > 0 {
+		args = append(args, pq.Array(deckIDs))
+		filter += " AND c.deck_id = ANY($3)"
+	}
+
+	var total int
+	countQuery := `
+		SELECT COUNT(*)
+		FROM cards c
+		WHERE c.search_vector @@ websearch_to_tsquery('simple', $1) AND c.deleted_at IS NULL ` + filter
+
+	if err := db.Get(&total, countQuery, args...); err != nil {
+		return nil, fmt.Errorf("failed to count search results: %w", err)
+	}
+
+	headlineOptions := fmt.Sprintf(`StartSel="%s", StopSel="%s", MaxFragments=2, MinWords=5, MaxWords=20`, highlightStart, highlightStop)
+	args = append(args, headlineOptions, limit, offset)
+	n := len(args)
+
+	query := fmt.Sprintf(`
+		SELECT c.id, c.deck_id, c.front, c.back, c.format, c.front_html, c.back_html, `+cardSuspendedColumn("$2")+`, c.created_at, c.updated_at,
+		`+cardTagsColumn+`,
+		ts_rank_cd(c.search_vector, q) AS rank,
+		ts_headline('simple', `+plainText("c.front_html")+`, q, $%d) AS front_snippet,
+		ts_headline('simple', `+plainText("c.back_html")+`, q, $%d) AS back_snippet
+		FROM cards c, websearch_to_tsquery('simple', $1) q
+		WHERE c.search_vector @@ q AND c.deleted_at IS NULL `+filter+`
+		ORDER BY rank DESC, c.id
+		LIMIT $%d OFFSET $%d`, n-2, n-2, n-1, n)
+
+	var results []models.SearchResult
+	if err := db.Select(&results, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to search cards: %w", err)
+	}
+
+	for i := range results {
+		results[i].FrontSnippet = content.Highlight(results[i].FrontSnippet, highlightStart, highlightStop)
+		results[i].BackSnippet = content.Highlight(results[i].BackSnippet, highlightStart, highlightStop)
+	}
+
+	if results == nil {
+		results = []models.SearchResult{}
+	}
+
+	return &models.SearchResults{
+		Results: results,
+		Total:   total,
+		Limit:   limit,
+		Offset:  offset,
+	}, nil
+}
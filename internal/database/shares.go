@@ -0,0 +1,111 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/dmltdev/flashcards/internal/models"
+)
+
+var (
+	ErrUserNotFound   = errors.New("no user with this email exists")
+	ErrShareWithOwner = errors.New("a deck cannot be shared with its owner")
+)
+
+// GetDeckShares lists the users a deck the user can view is shared with.
+// Shares of the parent decks, which extend to the deck, are not included.
+func (db *DB) GetDeckShares(userID, deckID int) ([]models.DeckShare, error) {
+	shares := []models.DeckShare{}
+	query := `
+		SELECT s.deck_id, s.user_id, u.email, u.name, s.role, s.created_at, s.updated_at
+		FROM deck_shares s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.deck_id = $1 AND s.deck_id IN (SELECT user_decks($2, 'viewer'))
+		ORDER BY s.created_at, s.user_id`
+
+	if err := db.Select(&shares, query, deckID, userID); err != nil {
+		return nil, fmt.Errorf("failed to get deck shares: %w", err)
+	}
+	return shares, nil
+}
+
+// ShareDeck shares a deck the user is an owner of with the user having
+// share.Email, or changes the role of an existing share.
+func (db *DB) ShareDeck(userID int, share *models.DeckShare) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := checkDeckRole(tx, userID, share.DeckID, models.DeckRoleOwner); err != nil {
+		return err
+	}
+
+	var target struct {
+		ID    int    `db:"id"`
+		Name  string `db:"name"`
+		Owner bool   `db:"owner"`
+	}
+	userQuery := `
+		SELECT u.id, u.name, u.id = d.user_id AS owner
+		FROM users u, decks d
+		WHERE u.email = $1 AND d.id = $2`
+	if err := tx.Get(&target, userQuery, share.Email, share.DeckID); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrUserNotFound
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if target.Owner {
+		return ErrShareWithOwner
+	}
+
+	query := `
+		INSERT INTO deck_shares (deck_id, user_id, role, created_at, updated_at)
+		VALUES ($1, $2, $3, NOW(), NOW())
+		ON CONFLICT (deck_id, user_id) DO UPDATE SET role = EXCLUDED.role
+		RETURNING created_at, updated_at`
+
+	err = tx.QueryRow(query, share.DeckID, target.ID, share.Role).Scan(&share.CreatedAt, &share.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to share deck: %w", err)
+	}
+	share.UserID = target.ID
+	share.Name = target.Name
+
+	if err := returnSharedCards(tx, share.DeckID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// UnshareDeck removes the share of a deck with the target user. Owners of the
+// deck can remove any share and every user can leave a deck shared with them.
+func (db *DB) UnshareDeck(userID, deckID, targetUserID int) error {
+	query := `
+		DELETE FROM deck_shares
+		WHERE deck_id = $1 AND user_id = $2
+			AND ($2 = $3 OR deck_id IN (SELECT user_decks($3, 'owner')))`
+
+	result, err := db.Exec(query, deckID, targetUserID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to unshare deck: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("deck share not found")
+	}
+
+	return nil
+}
@@ -0,0 +1,30 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/dmltdev/flashcards/internal/models"
+)
+
+// FindSimilarCards returns the cards a user can view whose normalized front is
+// most similar to the given normalized text according to pg_trgm, above the
+// extension's similarity threshold. The card with excludeID is left out of
+// the results, pass 0 to keep all cards.
+func (db *DB) FindSimilarCards(userID int, normalized string, excludeID int, limit int) ([]models.SimilarCard, error) {
+	cards := []models.SimilarCard{}
+	query := `
+		SELECT c.id, c.deck_id, c.front, c.back, c.format, c.front_html, c.back_html, ` + cardSuspendedColumn("$4") + `, c.created_at, c.updated_at,
+		` + cardTagsColumn + `,
+		similarity(c.front_normalized, $1) AS similarity
+		FROM cards c
+		WHERE c.front_normalized % $1 AND c.id <> $2 AND c.deleted_at IS NULL
+			AND c.deck_id IN (SELECT user_decks($4, 'viewer'))
+		ORDER BY similarity DESC, c.id
+		LIMIT $3`
+
+	err := db.Select(&cards, query, normalized, excludeID, limit, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find similar cards: %w", err)
+	}
+	return cards, nil
+}
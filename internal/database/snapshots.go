@@ -0,0 +1,294 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+
+	"github.com/dmltdev/flashcards/internal/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// CreateDeckSnapshot archives the current content of a deck the user can
+// edit as its next version.
+func (db *DB) CreateDeckSnapshot(userID, deckID int, label, author string) (*models.DeckSnapshot, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Locking the deck serializes version numbers and keeps the content
+	// consistent with the snapshot
+	var locked int
+	lockQuery := `SELECT id FROM decks WHERE id = $1 AND id IN (SELECT user_decks($2, 'editor')) AND deleted_at IS NULL FOR UPDATE`
+	if err := tx.Get(&locked, lockQuery, deckID, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("deck not found")
+		}
+		return nil, fmt.Errorf("failed to lock deck: %w", err)
+	}
+
+	content, err := deckContent(tx, userID, deckID)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := models.DeckSnapshot{
+		DeckID:    deckID,
+		Label:     label,
+		CardCount: len(content.Cards),
+		Author:    author,
+		Content:   content,
+	}
+	query := `
+		INSERT INTO deck_snapshots (deck_id, version, label, card_count, author, content, created_at)
+		SELECT $1, COALESCE(MAX(version), 0) + 1, $2, $3, $4, $5, NOW()
+		FROM deck_snapshots WHERE deck_id = $1
+		RETURNING id, version, created_at`
+
+	err = tx.QueryRow(query, deckID, label, snapshot.CardCount, author, content).Scan(
+		&snapshot.ID, &snapshot.Version, &snapshot.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create deck snapshot: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &snapshot, nil
+}
+
+// GetDeckSnapshots lists the snapshots of a deck, newest first, without
+// their content.
+func (db *DB) GetDeckSnapshots(userID, deckID int) ([]models.DeckSnapshot, error) {
+	snapshots := []models.DeckSnapshot{}
+	query := `
+		SELECT s.id, s.deck_id, s.version, s.label, s.card_count, s.author, s.created_at
+		FROM deck_snapshots s
+		WHERE s.deck_id = $1 AND s.deck_id IN (SELECT user_decks($2, 'viewer'))
+		ORDER BY s.version DESC`
+
+	if err := db.Select(&snapshots, query, deckID, userID); err != nil {
+		return nil, fmt.Errorf("failed to get deck snapshots: %w", err)
+	}
+	return snapshots, nil
+}
+
+func (db *DB) GetDeckSnapshot(userID, deckID, version int) (*models.DeckSnapshot, error) {
+	var snapshot models.DeckSnapshot
+	query := `
+		SELECT s.id, s.deck_id, s.version, s.label, s.card_count, s.author, s.content, s.created_at
+		FROM deck_snapshots s
+		WHERE s.deck_id = $1 AND s.version = $2 AND s.deck_id IN (SELECT user_decks($3, 'viewer'))`
+
+	err := db.Get(&snapshot, query, deckID, version, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("deck snapshot not found")
+		}
+		return nil, fmt.Errorf("failed to get deck snapshot: %w", err)
+	}
+	return &snapshot, nil
+}
+
+// GetDeckContent returns the current content of a deck in the form it would
+// be archived by a snapshot.
+func (db *DB) GetDeckContent(userID, deckID int) (*models.SnapshotContent, error) {
+	return deckContent(db, userID, deckID)
+}
+
+func deckContent(q sqlx.Queryer, userID, deckID int) (*models.SnapshotContent, error) {
+	var deck struct {
+		Name     string             `db:"name"`
+		PresetID *int               `db:"preset_id"`
+		Options  models.DeckOptions `db:"options"`
+	}
+	query := `
+		SELECT name, preset_id, options
+		FROM decks
+		WHERE id = $1 AND id IN (SELECT user_decks($2, 'viewer')) AND deleted_at IS NULL`
+	if err := sqlx.Get(q, &deck, query, deckID, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("deck not found")
+		}
+		return nil, fmt.Errorf("failed to get deck: %w", err)
+	}
+
+	var cards []struct {
+		ID     int            `db:"id"`
+		Front  string         `db:"front"`
+		Back   string         `db:"back"`
+		Format string         `db:"format"`
+		Tags   pq.StringArray `db:"tags"`
+	}
+	cardsQuery := `
+		SELECT c.id, c.front, c.back, c.format,
+		` + cardTagsColumn + `
+		FROM cards c
+		WHERE COALESCE(c.original_deck_id, c.deck_id) = $1 AND c.deleted_at IS NULL
+		ORDER BY c.id`
+
+	if err := sqlx.Select(q, &cards, cardsQuery, deckID); err != nil {
+		return nil, fmt.Errorf("failed to get cards: %w", err)
+	}
+
+	content := models.SnapshotContent{
+		Name:     deck.Name,
+		PresetID: deck.PresetID,
+		Options:  deck.Options,
+		Cards:    make([]models.SnapshotCard, len(cards)),
+	}
+	for i, card := range cards {
+		content.Cards[i] = models.SnapshotCard{
+			ID:     card.ID,
+			Front:  card.Front,
+			Back:   card.Back,
+			Format: card.Format,
+			Tags:   card.Tags,
+		}
+	}
+	return &content, nil
+}
+
+// RestoreDeckSnapshot rolls a deck the user is an owner of back to a
+// snapshot. The given cards are the rendered cards of the snapshot. Cards
+// that still exist keep their ID and review history, trashed cards are
+// brought back, cards that were purged or moved to another deck are created
+// again and cards added since the snapshot go to the trash.
+func (db *DB) RestoreDeckSnapshot(userID int, snapshot *models.DeckSnapshot, cards []*models.Card) (*models.SnapshotRestore, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	deckID := snapshot.DeckID
+	restore := models.SnapshotRestore{Version: snapshot.Version}
+
+	query := `
+		UPDATE decks SET preset_id = $1, options = $2, updated_at = NOW()
+		WHERE id = $3 AND id IN (SELECT user_decks($4, 'owner')) AND deleted_at IS NULL`
+
+	// The preset may have been deleted since the snapshot was taken
+	presetID := snapshot.Content.PresetID
+	if presetID != nil {
+		var exists bool
+		presetQuery := `SELECT EXISTS (SELECT 1 FROM presets p JOIN decks d ON d.user_id = p.user_id WHERE p.id = $1 AND d.id = $2)`
+		if err := tx.Get(&exists, presetQuery, *presetID, deckID); err != nil {
+			return nil, fmt.Errorf("failed to get preset: %w", err)
+		}
+		if !exists {
+			presetID = nil
+		}
+	}
+
+	result, err := tx.Exec(query, presetID, snapshot.Content.Options, deckID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore deck options: %w", err)
+	}
+	if rowsAffected, err := result.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	} else if rowsAffected == 0 {
+		return nil, fmt.Errorf("deck not found")
+	}
+
+	current, err := deckContent(tx, userID, deckID)
+	if err != nil {
+		return nil, err
+	}
+	live := make(map[int]models.SnapshotCard, len(current.Cards))
+	for _, card := range current.Cards {
+		live[card.ID] = card
+	}
+
+	var keep []int
+	for _, card := range cards {
+		existing, ok := live[card.ID]
+		switch {
+		case ok:
+			if existing.Front != card.Front || existing.Back != card.Back || existing.Format != card.Format {
+				if err := updateCard(tx, userID, card); err != nil {
+					return nil, err
+				}
+				restore.Updated++
+			} else if !slices.Equal(existing.Tags, card.Tags) {
+				restore.Updated++
+			}
+		default:
+			restored, err := untrashCard(tx, card.ID, deckID)
+			if err != nil {
+				return nil, err
+			}
+			if restored {
+				if err := updateCard(tx, userID, card); err != nil {
+					return nil, err
+				}
+				restore.Restored++
+			} else {
+				card.ID = 0
+				if err := createCard(tx, userID, card); err != nil {
+					return nil, err
+				}
+				restore.Created++
+			}
+		}
+
+		if err := setCardTags(tx, card.ID, card.Tags); err != nil {
+			return nil, err
+		}
+		keep = append(keep, card.ID)
+	}
+
+	trashQuery := `
+		UPDATE cards
+		SET deck_id = COALESCE(original_deck_id, deck_id), original_deck_id = NULL, deleted_at = NOW()
+		WHERE COALESCE(original_deck_id, deck_id) = $1 AND deleted_at IS NULL AND NOT (id = ANY($2))`
+
+	result, err = tx.Exec(trashQuery, deckID, pq.Array(keep))
+	if err != nil {
+		return nil, fmt.Errorf("failed to trash cards: %w", err)
+	}
+	trashed, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	restore.Trashed = int(trashed)
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &restore, nil
+}
+
+// untrashCard brings a trashed card of the deck back and reports whether
+// there was such a card.
+func untrashCard(tx *sqlx.Tx, cardID, deckID int) (bool, error) {
+	query := `UPDATE cards SET deleted_at = NULL WHERE id = $1 AND deck_id = $2 AND deleted_at IS NOT NULL`
+
+	result, err := tx.Exec(query, cardID, deckID)
+	if err != nil {
+		return false, fmt.Errorf("failed to restore card: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rowsAffected > 0, nil
+}
+
+// setCardTags replaces the tags of a card with the given ones.
+func setCardTags(tx *sqlx.Tx, cardID int, names []string) error {
+	query := `
+		DELETE FROM card_tags ct
+		USING tags t
+		WHERE t.id = ct.tag_id AND ct.card_id = $1 AND NOT (t.name = ANY($2))`
+
+	if _, err := tx.Exec(query, cardID, pq.Array(names)); err != nil {
+		return fmt.Errorf("failed to remove card tags: %w", err)
+	}
+
+	_, err := addTags(tx, []int{cardID}, names)
+	return err
+}
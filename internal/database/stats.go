@@ -0,0 +1,97 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/dmltdev/flashcards/internal/models"
+	"github.com/lib/pq"
+)
+
+// deckReviewsJoin restricts reviews to those by the user $2 of the live
+// cards of the subtree.
+const deckReviewsJoin = `
+	reviews rv JOIN cards c ON c.id = rv.card_id
+		AND rv.user_id = $2
+		AND c.deck_id IN (SELECT id FROM subtree)
+		AND c.deleted_at IS NULL`
+
+// GetDeckStats computes the statistics of a deck and its subdecks from the
+// reviews of the user. Retention is computed for each of the given windows
+// in days and the reviews per day cover the last days days, including today.
+func (db *DB) GetDeckStats(userID, deckID int, windows []int, days int) (*models.DeckStats, error) {
+	stats := models.DeckStats{DeckID: deckID}
+
+	var cards struct {
+		TotalCards int `db:"total_cards"`
+		models.CardStateCounts
+		AverageIntervalDays *float64 `db:"average_interval_days"`
+	}
+	cardsQuery := deckSubtreeCTE(models.DeckRoleViewer) + `
+		SELECT
+			COUNT(*) AS total_cards,
+			COUNT(*) FILTER (WHERE s.card_id IS NULL AND r.card_id IS NULL) AS new,
+			COUNT(*) FILTER (WHERE s.card_id IS NULL AND (r.quality < 3 OR r.interval < make_interval(secs => $3))) AS learning,
+			COUNT(*) FILTER (WHERE s.card_id IS NULL AND r.quality >= 3 AND r.interval >= make_interval(secs => $3) AND r.interval < make_interval(secs => $4)) AS young,
+			COUNT(*) FILTER (WHERE s.card_id IS NULL AND r.quality >= 3 AND r.interval >= make_interval(secs => $4)) AS mature,
+			COUNT(s.card_id) AS suspended,
+			AVG(EXTRACT(EPOCH FROM r.interval) / 86400)::FLOAT8 AS average_interval_days
+		FROM cards c
+		LEFT JOIN (
+			SELECT DISTINCT ON (card_id) card_id, quality, next_review_at - reviewed_at AS interval
+			FROM reviews
+			WHERE user_id = $2
+			ORDER BY card_id, reviewed_at DESC
+		) r ON c.id = r.card_id
+		LEFT JOIN card_suspensions s ON s.card_id = c.id AND s.user_id = $2
+		WHERE c.deck_id IN (SELECT id FROM subtree) AND c.deleted_at IS NULL`
+
+	if err := db.Get(&cards, cardsQuery, deckID, userID, models.GoodInterval.Seconds(), models.MatureInterval.Seconds()); err != nil {
+		return nil, fmt.Errorf("failed to get card states: %w", err)
+	}
+	stats.TotalCards = cards.TotalCards
+	stats.States = cards.CardStateCounts
+	stats.AverageIntervalDays = cards.AverageIntervalDays
+
+	reviewsQuery := deckSubtreeCTE(models.DeckRoleViewer) + `
+		SELECT COUNT(*) AS total_reviews, AVG(rv.quality)::FLOAT8 AS average_quality,
+			COALESCE(SUM(rv.duration_ms), 0) AS study_time_ms
+		FROM ` + deckReviewsJoin
+
+	if err := db.Get(&stats, reviewsQuery, deckID, userID); err != nil {
+		return nil, fmt.Errorf("failed to get review totals: %w", err)
+	}
+
+	retentionQuery := deckSubtreeCTE(models.DeckRoleViewer) + `
+		SELECT w.days, COUNT(rv.id) AS reviews, COUNT(rv.id) FILTER (WHERE rv.quality >= 3) AS passed
+		FROM unnest($3::INTEGER[]) AS w(days)
+		LEFT JOIN (` + deckReviewsJoin + `
+		) ON rv.reviewed_at >= NOW() - make_interval(days => w.days)
+		GROUP BY w.days
+		ORDER BY w.days`
+
+	if err := db.Select(&stats.Retention, retentionQuery, deckID, userID, pq.Array(windows)); err != nil {
+		return nil, fmt.Errorf("failed to get retention: %w", err)
+	}
+	for i := range stats.Retention {
+		window := &stats.Retention[i]
+		if window.Reviews > 0 {
+			retention := float64(window.Passed) / float64(window.Reviews)
+			window.Retention = &retention
+		}
+	}
+
+	perDayQuery := deckSubtreeCTE(models.DeckRoleViewer) + `
+		SELECT to_char(d.day, 'YYYY-MM-DD') AS date, COUNT(rv.id) AS reviews,
+			COALESCE(SUM(rv.duration_ms), 0) AS study_time_ms
+		FROM generate_series(CURRENT_DATE - ($3::INTEGER - 1), CURRENT_DATE, INTERVAL '1 day') AS d(day)
+		LEFT JOIN (` + deckReviewsJoin + `
+		) ON rv.reviewed_at >= d.day AND rv.reviewed_at < d.day + INTERVAL '1 day'
+		GROUP BY d.day
+		ORDER BY d.day`
+
+	if err := db.Select(&stats.ReviewsPerDay, perDayQuery, deckID, userID, days); err != nil {
+		return nil, fmt.Errorf("failed to get reviews per day: %w", err)
+	}
+
+	return &stats, nil
+}
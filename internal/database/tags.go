@@ -0,0 +1,177 @@
+package database
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/dmltdev/flashcards/internal/models"
+	"github.com/dmltdev/flashcards/internal/tagexpr"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// cardTagsColumn selects the sorted tag names of the card aliased as c.
+const cardTagsColumn = `COALESCE((
+			SELECT array_agg(t.name ORDER BY t.name)
+			FROM card_tags ct JOIN tags t ON t.id = ct.tag_id
+			WHERE ct.card_id = c.id
+		), '{}') AS tags`
+
+// cardSuspendedColumn selects whether the card aliased as c is suspended by
+// the user bound to the given parameter.
+func cardSuspendedColumn(userParam string) string {
+	return `EXISTS (
+			SELECT 1 FROM card_suspensions cs WHERE cs.card_id = c.id AND cs.user_id = ` + userParam + `
+		) AS suspended`
+}
+
+func (db *DB) AddCardTags(userID, cardID int, names []string) error {
+	var exists bool
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM cards
+			WHERE id = $1 AND deleted_at IS NULL
+				AND deck_id IN (SELECT user_decks($2, 'editor'))
+		)`
+	if err := db.Get(&exists, query, cardID, userID); err != nil {
+		return fmt.Errorf("failed to get card: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("card not found")
+	}
+
+	_, err := db.BulkTagCards(userID, []int{cardID}, names)
+	return err
+}
+
+// BulkTagCards adds the tags to every card in cardIDs the user can edit and
+// returns the number of new card-tag associations. Card IDs that do not
+// exist or that the user cannot edit are ignored.
+func (db *DB) BulkTagCards(userID int, cardIDs []int, names []string) (int64, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var owned []int
+	query := `
+		SELECT id FROM cards
+		WHERE id = ANY($1) AND deck_id IN (SELECT user_decks($2, 'editor'))`
+	if err := tx.Select(&owned, query, pq.Array(cardIDs), userID); err != nil {
+		return 0, fmt.Errorf("failed to get cards: %w", err)
+	}
+
+	count, err := addTags(tx, owned, names)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return count, nil
+}
+
+func (db *DB) RemoveCardTag(userID, cardID int, name string) error {
+	query := `
+		DELETE FROM card_tags ct
+		USING tags t, cards c
+		WHERE t.id = ct.tag_id AND c.id = ct.card_id
+			AND ct.card_id = $1 AND t.name = $2 AND c.deleted_at IS NULL
+			AND c.deck_id IN (SELECT user_decks($3, 'editor'))`
+
+	result, err := db.Exec(query, cardID, name, userID)
+	if err != nil {
+		return fmt.Errorf("failed to remove card tag: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("card tag not found")
+	}
+
+	return nil
+}
+
+// GetAllTags returns the tags used on the cards a user can view. Tag names
+// are shared between users, so tags only used elsewhere are left out and
+// only the name and card count are returned.
+func (db *DB) GetAllTags(userID int) ([]models.Tag, error) {
+	tags := []models.Tag{}
+	query := `
+		SELECT t.name, COUNT(c.id) as card_count
+		FROM tags t
+		JOIN card_tags ct ON t.id = ct.tag_id
+		JOIN cards c ON c.id = ct.card_id AND c.deleted_at IS NULL
+			AND c.deck_id IN (SELECT user_decks($1, 'viewer'))
+		GROUP BY t.name
+		ORDER BY t.name`
+
+	err := db.Select(&tags, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tags: %w", err)
+	}
+	return tags, nil
+}
+
+func addTags(tx *sqlx.Tx, cardIDs []int, names []string) (int64, error) {
+	if len(cardIDs) == 0 || len(names) == 0 {
+		return 0, nil
+	}
+
+	query := `
+		INSERT INTO tags (name, created_at, updated_at)
+		SELECT unnest($1::text[]), NOW(), NOW()
+		ON CONFLICT (name) DO NOTHING`
+
+	if _, err := tx.Exec(query, pq.Array(names)); err != nil {
+		return 0, fmt.Errorf("failed to create tags: %w", err)
+	}
+
+	query = `
+		INSERT INTO card_tags (card_id, tag_id)
+		SELECT c.id, t.id
+		FROM cards c CROSS JOIN tags t
+		WHERE c.id = ANY($1) AND t.name = ANY($2) AND c.deleted_at IS NULL
+		ON CONFLICT DO NOTHING`
+
+	result, err := tx.Exec(query, pq.Array(cardIDs), pq.Array(names))
+	if err != nil {
+		return 0, fmt.Errorf("failed to tag cards: %w", err)
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return count, nil
+}
+
+// tagFilterSQL translates a tag expression into a boolean SQL condition on
+// the card aliased as c. Placeholders continue after the existing args.
+func tagFilterSQL(expr tagexpr.Expr, args []any) (string, []any) {
+	switch e := expr.(type) {
+	case tagexpr.Tag:
+		args = append(args, e.Name)
+		return `EXISTS (
+			SELECT 1 FROM card_tags ct JOIN tags t ON t.id = ct.tag_id
+			WHERE ct.card_id = c.id AND t.name = $` + strconv.Itoa(len(args)) + `)`, args
+	case tagexpr.Not:
+		inner, args := tagFilterSQL(e.Expr, args)
+		return "NOT " + inner, args
+	case tagexpr.And:
+		left, args := tagFilterSQL(e.Left, args)
+		right, args := tagFilterSQL(e.Right, args)
+		return "(" + left + " AND " + right + ")", args
+	case tagexpr.Or:
+		left, args := tagFilterSQL(e.Left, args)
+		right, args := tagFilterSQL(e.Right, args)
+		return "(" + left + " OR " + right + ")", args
+	}
+	return "TRUE", args
+}
@@ -0,0 +1,133 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+
+// CreateRefreshToken stores the hash of a new refresh token of the user and
+// drops the user's tokens that have expired.
+func (db *DB) CreateRefreshToken(userID int, tokenHash string, expiresAt time.Time) error {
+	if _, err := db.Exec(`DELETE FROM refresh_tokens WHERE user_id = $1 AND expires_at < NOW()`, userID); err != nil {
+		return fmt.Errorf("failed to delete expired refresh tokens: %w", err)
+	}
+
+	query := `
+		INSERT INTO refresh_tokens (user_id, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, NOW())`
+
+	if _, err := db.Exec(query, userID, tokenHash, expiresAt); err != nil {
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+	return nil
+}
+
+// RotateRefreshToken revokes a refresh token and stores its replacement,
+// returning the ID of the user it belongs to. A token that was already
+// revoked is being reused, most likely by someone who stole it, so all
+// refresh tokens of its user are revoked as well.
+func (db *DB) RotateRefreshToken(tokenHash, newTokenHash string, expiresAt time.Time) (int, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var token struct {
+		UserID    int          `db:"user_id"`
+		ExpiresAt time.Time    `db:"expires_at"`
+		RevokedAt sql.NullTime `db:"revoked_at"`
+	}
+	query := `
+		SELECT user_id, expires_at, revoked_at
+		FROM refresh_tokens
+		WHERE token_hash = $1
+		FOR UPDATE`
+
+	if err := tx.Get(&token, query, tokenHash); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrInvalidRefreshToken
+		}
+		return 0, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+
+	if token.RevokedAt.Valid {
+		if err := revokeUserRefreshTokens(tx, token.UserID); err != nil {
+			return 0, err
+		}
+		if err := tx.Commit(); err != nil {
+			return 0, fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		return 0, ErrInvalidRefreshToken
+	}
+
+	if !token.ExpiresAt.After(time.Now()) {
+		return 0, ErrInvalidRefreshToken
+	}
+
+	if _, err := tx.Exec(`UPDATE refresh_tokens SET revoked_at = NOW() WHERE token_hash = $1`, tokenHash); err != nil {
+		return 0, fmt.Errorf("failed to revoke refresh token: %w", err)
+	}
+
+	insertQuery := `
+		INSERT INTO refresh_tokens (user_id, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, NOW())`
+
+	if _, err := tx.Exec(insertQuery, token.UserID, newTokenHash, expiresAt); err != nil {
+		return 0, fmt.Errorf("failed to create refresh token: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return token.UserID, nil
+}
+
+// RevokeRefreshToken revokes a refresh token, or with all set every refresh
+// token of the same user, signing them out everywhere.
+func (db *DB) RevokeRefreshToken(tokenHash string, all bool) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var userID int
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = NOW()
+		WHERE token_hash = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		RETURNING user_id`
+
+	if err := tx.Get(&userID, query, tokenHash); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrInvalidRefreshToken
+		}
+		return fmt.Errorf("failed to revoke refresh token: %w", err)
+	}
+
+	if all {
+		if err := revokeUserRefreshTokens(tx, userID); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func revokeUserRefreshTokens(tx *sqlx.Tx, userID int) error {
+	query := `UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`
+	if _, err := tx.Exec(query, userID); err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+	return nil
+}
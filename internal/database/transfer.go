@@ -0,0 +1,166 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/dmltdev/flashcards/internal/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+var ErrMoveToOtherOwner = errors.New("only the owner of the cards can move them to a deck of another owner")
+
+// MoveCards moves cards to another deck. The user must be able to edit both
+// the cards and the target deck, and must own the cards to move them to a
+// deck of another owner, which would take them away from their owner and
+// everyone the deck is shared with. The cards keep their IDs, so their review
+// history and scheduling move with them. Cards borrowed by a filtered deck
+// get the target as their new home. Either all cards are moved or none.
+func (db *DB) MoveCards(userID int, cardIDs []int, deckID int) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := checkDeckRole(tx, userID, deckID, models.DeckRoleEditor); err != nil {
+		return err
+	}
+
+	var foreign bool
+	foreignQuery := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM cards c
+			JOIN decks src ON src.id = c.deck_id
+			JOIN decks dst ON dst.id = $2
+			WHERE c.id = ANY($1) AND c.deleted_at IS NULL AND src.user_id <> dst.user_id
+				AND c.deck_id IN (SELECT user_decks($3, 'viewer'))
+				AND c.deck_id NOT IN (SELECT user_decks($3, 'owner'))
+		)`
+	if err := tx.Get(&foreign, foreignQuery, pq.Array(cardIDs), deckID, userID); err != nil {
+		return fmt.Errorf("failed to get cards: %w", err)
+	}
+	if foreign {
+		return ErrMoveToOtherOwner
+	}
+
+	query := `
+		UPDATE cards
+		SET deck_id = $1, original_deck_id = NULL, updated_at = NOW()
+		WHERE id = ANY($2) AND deleted_at IS NULL
+			AND deck_id IN (SELECT user_decks($3, 'editor'))`
+
+	result, err := tx.Exec(query, deckID, pq.Array(cardIDs), userID)
+	if err != nil {
+		return fmt.Errorf("failed to move cards: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected != int64(len(cardIDs)) {
+		return fmt.Errorf("card not found")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// CopyCards copies cards the user can view with their tags and media into a
+// deck the user can edit. The copies start with fresh scheduling state and no
+// history. Either all cards are copied or none.
+func (db *DB) CopyCards(userID int, cardIDs []int, deckID int, author string) ([]models.Card, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := checkDeckRole(tx, userID, deckID, models.DeckRoleEditor); err != nil {
+		return nil, err
+	}
+
+	var copyIDs []int
+	for _, cardID := range cardIDs {
+		copyID, err := copyCard(tx, userID, cardID, deckID, author, true)
+		if err != nil {
+			return nil, err
+		}
+		copyIDs = append(copyIDs, copyID)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return db.GetCardsByIDs(userID, copyIDs)
+}
+
+// copyCard duplicates a single card the user can view with its media references,
+// and its tags if withTags is set, into the given deck and returns the ID of
+// the copy.
+func copyCard(tx *sqlx.Tx, userID, cardID, deckID int, author string, withTags bool) (int, error) {
+	var copyID int
+	query := `
+		INSERT INTO cards (deck_id, front, back, format, front_html, back_html, front_normalized, updated_by, created_at, updated_at)
+		SELECT $1, front, back, format, front_html, back_html, front_normalized, $2, NOW(), NOW()
+		FROM cards
+		WHERE id = $3 AND deleted_at IS NULL
+			AND deck_id IN (SELECT user_decks($4, 'viewer'))
+		RETURNING id`
+
+	err := tx.QueryRow(query, deckID, author, cardID, userID).Scan(&copyID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("card not found")
+		}
+		return 0, fmt.Errorf("failed to copy card: %w", err)
+	}
+
+	if withTags {
+		_, err = tx.Exec(`INSERT INTO card_tags (card_id, tag_id) SELECT $1, tag_id FROM card_tags WHERE card_id = $2`, copyID, cardID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to copy card tags: %w", err)
+		}
+	}
+
+	_, err = tx.Exec(`INSERT INTO card_media (card_id, media_id) SELECT $1, media_id FROM card_media WHERE card_id = $2`, copyID, cardID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to copy card media: %w", err)
+	}
+
+	return copyID, nil
+}
+
+func checkDeckExists(tx *sqlx.Tx, userID, deckID int) error {
+	var exists bool
+	err := tx.Get(&exists, `SELECT EXISTS (SELECT 1 FROM decks WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL)`, deckID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get deck: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("deck not found")
+	}
+	return nil
+}
+
+// checkDeckRole checks that the deck exists and the user holds at least the
+// given role on it.
+func checkDeckRole(tx *sqlx.Tx, userID, deckID int, role string) error {
+	var exists bool
+	query := `SELECT EXISTS (SELECT 1 FROM decks WHERE id = $1 AND id IN (SELECT user_decks($2, $3)) AND deleted_at IS NULL)`
+	if err := tx.Get(&exists, query, deckID, userID, role); err != nil {
+		return fmt.Errorf("failed to get deck: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("deck not found")
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dmltdev/flashcards/internal/models"
+)
+
+// GetTrash lists the deleted decks a user is an owner of and the cards the
+// user can edit that were deleted on their own. Subdecks and cards deleted
+// along with their deck are only reflected in the deck's card count.
+func (db *DB) GetTrash(userID int) (*models.Trash, error) {
+	trash := models.Trash{
+		Decks: []models.Deck{},
+		Cards: []models.Card{},
+	}
+
+	decksQuery := `
+		SELECT
+			d.id,
+			d.name,
+			d.created_at,
+			d.updated_at,
+			d.deleted_at,
+			COUNT(c.id) as card_count
+		FROM decks d
+		LEFT JOIN cards c ON d.id = c.deck_id AND c.deleted_at = d.deleted_at
+		WHERE d.id IN (SELECT user_decks($1, 'owner')) AND d.deleted_at IS NOT NULL
+			AND NOT EXISTS (SELECT 1 FROM decks p WHERE p.id = d.parent_id AND p.deleted_at IS NOT NULL)
+		GROUP BY d.id
+		ORDER BY d.deleted_at DESC`
+
+	if err := db.Select(&trash.Decks, decksQuery, userID); err != nil {
+		return nil, fmt.Errorf("failed to get deleted decks: %w", err)
+	}
+
+	cardsQuery := `
+		SELECT c.id, c.deck_id, c.front, c.back, c.format, c.front_html, c.back_html, ` + cardSuspendedColumn("$1") + `, c.created_at, c.updated_at, c.deleted_at,
+		` + cardTagsColumn + `
+		FROM cards c
+		JOIN decks d ON d.id = c.deck_id
+		WHERE c.deleted_at IS NOT NULL AND d.deleted_at IS NULL AND d.id IN (SELECT user_decks($1, 'editor'))
+		ORDER BY c.deleted_at DESC`
+
+	if err := db.Select(&trash.Cards, cardsQuery, userID); err != nil {
+		return nil, fmt.Errorf("failed to get deleted cards: %w", err)
+	}
+
+	return &trash, nil
+}
+
+// trashedSubtreeCTE selects the trashed deck $1 the user $2 is an owner of
+// and the subdecks that were trashed along with it as the "subtree" relation.
+// A deck whose parent is still in the trash cannot be restored on its own.
+const trashedSubtreeCTE = `
+	WITH RECURSIVE subtree AS (
+		SELECT d.id, d.deleted_at FROM decks d
+		WHERE d.id = $1 AND d.id IN (SELECT user_decks($2, 'owner')) AND d.deleted_at IS NOT NULL
+			AND NOT EXISTS (SELECT 1 FROM decks p WHERE p.id = d.parent_id AND p.deleted_at IS NOT NULL)
+		UNION ALL
+		SELECT d.id, d.deleted_at FROM decks d JOIN subtree s ON d.parent_id = s.id
+		WHERE d.deleted_at = s.deleted_at
+	)`
+
+// RestoreDeck brings a deck back from the trash together with the subdecks
+// and cards that were deleted with it.
+func (db *DB) RestoreDeck(userID, id int) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	cardsQuery := trashedSubtreeCTE + `
+		UPDATE cards c
+		SET deleted_at = NULL
+		FROM subtree s
+		WHERE c.deck_id = s.id AND c.deleted_at = s.deleted_at`
+
+	if _, err := tx.Exec(cardsQuery, id, userID); err != nil {
+		return fmt.Errorf("failed to restore deck cards: %w", err)
+	}
+
+	query := trashedSubtreeCTE + `
+		UPDATE decks d
+		SET deleted_at = NULL
+		FROM subtree s
+		WHERE d.id = s.id`
+
+	result, err := tx.Exec(query, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to restore deck: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("deck not found in trash")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// RestoreCard brings a card back from the trash. Cards of a deleted deck
+// can only be restored by restoring the deck.
+func (db *DB) RestoreCard(userID, id int) error {
+	query := `
+		UPDATE cards c
+		SET deleted_at = NULL
+		FROM decks d
+		WHERE c.id = $1 AND c.deleted_at IS NOT NULL
+			AND d.id = c.deck_id AND d.id IN (SELECT user_decks($2, 'editor')) AND d.deleted_at IS NULL`
+
+	result, err := db.Exec(query, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to restore card: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("card not found in trash")
+	}
+
+	return nil
+}
+
+// PurgeTrash permanently deletes decks and cards that have been in the trash
+// for longer than the retention period, along with their review history.
+func (db *DB) PurgeTrash(retention time.Duration) (decks int64, cards int64, err error) {
+	cutoff := time.Now().Add(-retention)
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM cards WHERE deleted_at < $1`, cutoff)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to purge cards: %w", err)
+	}
+	if cards, err = result.RowsAffected(); err != nil {
+		return 0, 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	result, err = tx.Exec(`DELETE FROM decks WHERE deleted_at < $1`, cutoff)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to purge decks: %w", err)
+	}
+	if decks, err = result.RowsAffected(); err != nil {
+		return 0, 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return decks, cards, nil
+}
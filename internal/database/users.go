@@ -0,0 +1,68 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/dmltdev/flashcards/internal/models"
+	"github.com/lib/pq"
+)
+
+var ErrEmailTaken = errors.New("a user with this email already exists")
+
+// isUniqueViolation reports whether err is a unique constraint violation.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+func (db *DB) CreateUser(user *models.User) error {
+	query := `
+		INSERT INTO users (email, name, password_hash, created_at, updated_at)
+		VALUES ($1, $2, $3, NOW(), NOW())
+		RETURNING id, created_at, updated_at`
+
+	err := db.QueryRow(query, user.Email, user.Name, user.PasswordHash).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrEmailTaken
+		}
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) GetUser(id int) (*models.User, error) {
+	var user models.User
+	query := `SELECT id, email, name, created_at, updated_at FROM users WHERE id = $1`
+
+	err := db.Get(&user, query, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("user not found")
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return &user, nil
+}
+
+// GetUserByEmail returns the user with the given email including its
+// password hash, which is empty for accounts without a password, or nil if
+// there is no such user.
+func (db *DB) GetUserByEmail(email string) (*models.User, error) {
+	var user models.User
+	query := `
+		SELECT id, email, name, COALESCE(password_hash, '') AS password_hash, created_at, updated_at
+		FROM users
+		WHERE email = $1`
+
+	err := db.Get(&user, query, email)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return &user, nil
+}
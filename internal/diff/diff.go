@@ -0,0 +1,122 @@
+package diff
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	OpEqual  = "equal"
+	OpInsert = "insert"
+	OpDelete = "delete"
+)
+
+type Change struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// maxCells caps the size of the table used to diff the tokens that differ.
+// Above it the changed part is reported as a whole, which keeps memory
+// bounded for large texts.
+const maxCells = 1 << 20
+
+// Words computes a word level diff turning a into b. Whitespace is kept as
+// separate tokens so that joining the text of all equal and insert changes
+// reproduces b exactly.
+func Words(a, b string) []Change {
+	x, y := tokenize(a), tokenize(b)
+
+	var changes []Change
+	add := func(op string, tokens ...string) {
+		text := strings.Join(tokens, "")
+		if text == "" {
+			return
+		}
+		if n := len(changes); n > 0 && changes[n-1].Op == op {
+			changes[n-1].Text += text
+			return
+		}
+		changes = append(changes, Change{Op: op, Text: text})
+	}
+
+	// Common leading and trailing tokens need no table
+	prefix := 0
+	for prefix < len(x) && prefix < len(y) && x[prefix] == y[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(x)-prefix && suffix < len(y)-prefix && x[len(x)-1-suffix] == y[len(y)-1-suffix] {
+		suffix++
+	}
+	add(OpEqual, x[:prefix]...)
+	tail := x[len(x)-suffix:]
+	x, y = x[prefix:len(x)-suffix], y[prefix:len(y)-suffix]
+
+	if (len(x)+1)*(len(y)+1) > maxCells {
+		add(OpDelete, x...)
+		add(OpInsert, y...)
+		add(OpEqual, tail...)
+		return changes
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of x[i:] and y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			add(OpEqual, x[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			add(OpDelete, x[i])
+			i++
+		default:
+			add(OpInsert, y[j])
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		add(OpDelete, x[i])
+	}
+	for ; j < len(y); j++ {
+		add(OpInsert, y[j])
+	}
+	add(OpEqual, tail...)
+
+	return changes
+}
+
+func tokenize(s string) []string {
+	var tokens []string
+	var current strings.Builder
+	space := false
+
+	for _, r := range s {
+		if current.Len() > 0 && unicode.IsSpace(r) != space {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+		space = unicode.IsSpace(r)
+		current.WriteRune(r)
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+
+	return tokens
+}
@@ -0,0 +1,80 @@
+package diff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWords(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []Change
+	}{
+		{"both empty", "", "", nil},
+		{"equal", "hello world", "hello world", []Change{{OpEqual, "hello world"}}},
+		{"insert into empty", "", "hello", []Change{{OpInsert, "hello"}}},
+		{"delete everything", "hello", "", []Change{{OpDelete, "hello"}}},
+		{"replace a word", "the red car", "the blue car", []Change{
+			{OpEqual, "the "}, {OpDelete, "red"}, {OpInsert, "blue"}, {OpEqual, " car"},
+		}},
+		{"append a word", "the car", "the car park", []Change{
+			{OpEqual, "the car"}, {OpInsert, " park"},
+		}},
+		{"remove a word", "a big dog", "a dog", []Change{
+			{OpEqual, "a "}, {OpDelete, "big "}, {OpEqual, "dog"},
+		}},
+		{"whitespace change", "a b", "a  b", []Change{
+			{OpEqual, "a"}, {OpDelete, " "}, {OpInsert, "  "}, {OpEqual, "b"},
+		}},
+		{"middle edits", "one two three four", "one 2 three 4", []Change{
+			{OpEqual, "one "}, {OpDelete, "two"}, {OpInsert, "2"}, {OpEqual, " three "}, {OpDelete, "four"}, {OpInsert, "4"},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Words(tt.a, tt.b)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Words(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+			if a, b := rebuild(got); a != tt.a || b != tt.b {
+				t.Errorf("Words(%q, %q) rebuilds %q, %q", tt.a, tt.b, a, b)
+			}
+		})
+	}
+}
+
+func TestWordsLargeInput(t *testing.T) {
+	a := strings.Repeat("alpha ", 5000) + "start " + strings.Repeat("beta ", 5000)
+	b := strings.Repeat("alpha ", 5000) + "end " + strings.Repeat("gamma ", 5000)
+
+	got := Words(a, b)
+	want := []Change{
+		{OpEqual, strings.Repeat("alpha ", 5000)},
+		{OpDelete, "start " + strings.TrimSuffix(strings.Repeat("beta ", 5000), " ")},
+		{OpInsert, "end " + strings.TrimSuffix(strings.Repeat("gamma ", 5000), " ")},
+		{OpEqual, " "},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Words() on large input returned %d changes, want a whole-field diff", len(got))
+	}
+	if ra, rb := rebuild(got); ra != a || rb != b {
+		t.Error("Words() on large input does not rebuild its inputs")
+	}
+}
+
+// rebuild returns the texts a diff turns into each other.
+func rebuild(changes []Change) (string, string) {
+	var a, b strings.Builder
+	for _, c := range changes {
+		if c.Op != OpInsert {
+			a.WriteString(c.Text)
+		}
+		if c.Op != OpDelete {
+			b.WriteString(c.Text)
+		}
+	}
+	return a.String(), b.String()
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/dmltdev/flashcards/internal/auth"
+	"github.com/dmltdev/flashcards/internal/models"
+)
+
+// CreateAPIKey creates a key for the user. The response is the only time the
+// key itself is returned.
+func (h *Handler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	var key models.APIKey
+	if err := json.NewDecoder(r.Body).Decode(&key); err != nil {
+		log.Error("Invalid JSON", err)
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	key.UserID = userID
+
+	key.Normalize()
+	if err := key.Validate(); err != nil {
+		log.Error("Invalid API key", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	secret, prefix, hash, err := auth.NewAPIKey()
+	if err != nil {
+		log.Error("Failed to generate API key", err)
+		http.Error(w, "Failed to create API key", http.StatusInternalServerError)
+		return
+	}
+	key.Prefix = prefix
+
+	if err := h.db.CreateAPIKey(&key, hash); err != nil {
+		log.Error("Failed to create API key", err)
+		http.Error(w, "Failed to create API key", http.StatusInternalServerError)
+		return
+	}
+	key.Key = secret
+
+	log.Info("API key created", "api_key_id", key.ID, "scopes", key.Scopes)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(key)
+}
+
+func (h *Handler) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	keys, err := h.db.GetAPIKeys(userID)
+	if err != nil {
+		log.Error("Failed to get API keys", err)
+		http.Error(w, "Failed to get API keys", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keys)
+}
+
+func (h *Handler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Error("Invalid API key ID", err)
+		http.Error(w, "Invalid API key ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.RevokeAPIKey(userID, id); err != nil {
+		log.Error("Failed to revoke API key", err)
+		http.Error(w, "API key not found", http.StatusNotFound)
+		return
+	}
+
+	log.Info("API key revoked", "api_key_id", id)
+
+	w.WriteHeader(http.StatusNoContent)
+}
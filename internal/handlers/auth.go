@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/dmltdev/flashcards/internal/auth"
+	"github.com/dmltdev/flashcards/internal/database"
+	"github.com/dmltdev/flashcards/internal/models"
+)
+
+type registerRequest struct {
+	Email    string `json:"email"`
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+type loginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type refreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type tokenResponse struct {
+	AccessToken  string       `json:"access_token"`
+	TokenType    string       `json:"token_type"`
+	ExpiresIn    int          `json:"expires_in"`
+	RefreshToken string       `json:"refresh_token"`
+	User         *models.User `json:"user,omitempty"`
+}
+
+func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
+	var req registerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Error("Invalid JSON", err)
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	user := models.User{Email: req.Email, Name: req.Name}
+	user.Normalize()
+	if err := user.Validate(); err != nil {
+		log.Error("Invalid user", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := auth.ValidatePassword(req.Password); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		log.Error("Failed to hash password", err)
+		http.Error(w, "Failed to register", http.StatusInternalServerError)
+		return
+	}
+	user.PasswordHash = hash
+
+	if err := h.db.CreateUser(&user); err != nil {
+		log.Error("Failed to create user", err)
+		if errors.Is(err, database.ErrEmailTaken) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to register", http.StatusInternalServerError)
+		return
+	}
+
+	log.Info("User registered", "user_id", user.ID)
+
+	h.issueTokens(w, &user, http.StatusCreated)
+}
+
+func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
+	var req loginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Error("Invalid JSON", err)
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	credentials := models.User{Email: req.Email}
+	credentials.Normalize()
+
+	user, err := h.db.GetUserByEmail(credentials.Email)
+	if err != nil {
+		log.Error("Failed to get user", err)
+		http.Error(w, "Failed to log in", http.StatusInternalServerError)
+		return
+	}
+
+	hash := ""
+	if user != nil {
+		hash = user.PasswordHash
+	}
+	if !auth.CheckPassword(hash, req.Password) {
+		log.Warn("Failed login", "email", credentials.Email)
+		http.Error(w, "Invalid email or password", http.StatusUnauthorized)
+		return
+	}
+
+	log.Info("User logged in", "user_id", user.ID)
+
+	h.issueTokens(w, user, http.StatusOK)
+}
+
+// RefreshToken exchanges a refresh token for a new access token. The refresh
+// token is single use and replaced by the one in the response.
+func (h *Handler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var req refreshTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Error("Invalid JSON", err)
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	refreshToken, hash, expiresAt, err := h.tokens.NewRefreshToken()
+	if err != nil {
+		log.Error("Failed to generate refresh token", err)
+		http.Error(w, "Failed to refresh token", http.StatusInternalServerError)
+		return
+	}
+
+	userID, err := h.db.RotateRefreshToken(auth.HashToken(req.RefreshToken), hash, expiresAt)
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidRefreshToken) {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		log.Error("Failed to rotate refresh token", err)
+		http.Error(w, "Failed to refresh token", http.StatusInternalServerError)
+		return
+	}
+
+	accessToken, err := h.tokens.IssueAccessToken(userID)
+	if err != nil {
+		log.Error("Failed to issue access token", err)
+		http.Error(w, "Failed to refresh token", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(tokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(h.tokens.AccessTTL().Seconds()),
+		RefreshToken: refreshToken,
+	})
+}
+
+// Logout revokes a refresh token, or with ?all=true every refresh token of
+// its user. Access tokens already issued stay valid until they expire.
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	var req refreshTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Error("Invalid JSON", err)
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	all := r.URL.Query().Get("all") == "true"
+
+	if err := h.db.RevokeRefreshToken(auth.HashToken(req.RefreshToken), all); err != nil {
+		if errors.Is(err, database.ErrInvalidRefreshToken) {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		log.Error("Failed to revoke refresh token", err)
+		http.Error(w, "Failed to log out", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// issueTokens starts a session for the user and responds with its access
+// and refresh tokens.
+func (h *Handler) issueTokens(w http.ResponseWriter, user *models.User, status int) {
+	accessToken, err := h.tokens.IssueAccessToken(user.ID)
+	if err != nil {
+		log.Error("Failed to issue access token", err)
+		http.Error(w, "Failed to issue tokens", http.StatusInternalServerError)
+		return
+	}
+
+	refreshToken, hash, expiresAt, err := h.tokens.NewRefreshToken()
+	if err != nil {
+		log.Error("Failed to generate refresh token", err)
+		http.Error(w, "Failed to issue tokens", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.db.CreateRefreshToken(user.ID, hash, expiresAt); err != nil {
+		log.Error("Failed to store refresh token", err)
+		http.Error(w, "Failed to issue tokens", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(tokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(h.tokens.AccessTTL().Seconds()),
+		RefreshToken: refreshToken,
+		User:         user,
+	})
+}
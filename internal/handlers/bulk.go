@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/dmltdev/flashcards/internal/models"
+)
+
+const maxBulkCards = 1000
+
+type bulkCreateCardsRequest struct {
+	Cards []models.Card `json:"cards"`
+}
+
+type bulkCardResult struct {
+	Index          int    `json:"index"`
+	ID             int    `json:"id,omitempty"`
+	Merged         bool   `json:"merged,omitempty"`
+	ExistingCardID int    `json:"existing_card_id,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+type bulkCreateCardsResponse struct {
+	Results []bulkCardResult `json:"results"`
+	Created int              `json:"created"`
+	Merged  int              `json:"merged"`
+	Failed  int              `json:"failed"`
+}
+
+// BulkCreateCards creates many cards in one request. By default the batch is
+// atomic: any invalid card or duplicate rejects the whole batch. With
+// ?partial=true the valid cards are stored and the others reported.
+func (h *Handler) BulkCreateCards(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	idStr := r.PathValue("id")
+	deckID, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Error("Invalid deck ID", err)
+		http.Error(w, "Invalid deck ID", http.StatusBadRequest)
+		return
+	}
+
+	partial := r.URL.Query().Get("partial") == "true"
+
+	onDuplicate, err := parseOnDuplicate(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req bulkCreateCardsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Error("Invalid JSON", err)
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if len(req.Cards) == 0 {
+		http.Error(w, "cards cannot be empty", http.StatusBadRequest)
+		return
+	}
+	if len(req.Cards) > maxBulkCards {
+		http.Error(w, fmt.Sprintf("cannot create more than %d cards at once", maxBulkCards), http.StatusBadRequest)
+		return
+	}
+
+	deck, err := h.db.GetDeck(userID, deckID)
+	if err != nil {
+		log.Error("Failed to get deck", err)
+		http.Error(w, "Deck not found", http.StatusNotFound)
+		return
+	}
+	if deck.Kind == models.DeckKindFiltered {
+		http.Error(w, "Cannot add cards to a filtered deck", http.StatusBadRequest)
+		return
+	}
+	if !deck.CanEdit() {
+		http.Error(w, "Cannot add cards to a deck shared with you as a viewer", http.StatusForbidden)
+		return
+	}
+
+	resp := bulkCreateCardsResponse{Results: make([]bulkCardResult, len(req.Cards))}
+	prepared := make([]bool, len(req.Cards))
+	var fronts []string
+
+	for i := range req.Cards {
+		card := &req.Cards[i]
+		card.ID = 0
+		resp.Results[i].Index = i
+
+		if err := prepareCard(card, deckID, r); err != nil {
+			resp.Results[i].Error = err.Error()
+			continue
+		}
+		prepared[i] = true
+		fronts = append(fronts, card.FrontNormalized)
+	}
+
+	duplicates, err := h.db.FindDuplicateCards(userID, deckID, fronts)
+	if err != nil {
+		log.Error("Failed to check for duplicate cards", err)
+		http.Error(w, "Failed to create cards", http.StatusInternalServerError)
+		return
+	}
+
+	var valid []*models.Card
+	var validIndexes []int
+	seen := make(map[string]int)
+
+	for i := range req.Cards {
+		if !prepared[i] {
+			continue
+		}
+		card := &req.Cards[i]
+		result := &resp.Results[i]
+
+		if j, ok := seen[card.FrontNormalized]; ok {
+			result.Error = fmt.Sprintf("duplicate of card at index %d", j)
+			continue
+		}
+		seen[card.FrontNormalized] = i
+
+		if existing := duplicates[card.FrontNormalized]; existing != nil {
+			if onDuplicate == onDuplicateReject {
+				result.Error = "a card with the same front already exists in this deck"
+				result.ExistingCardID = existing.ID
+				continue
+			}
+			// Cards carrying an ID are merged into that card by ImportCards
+			card.ID = existing.ID
+			result.Merged = true
+		}
+
+		valid = append(valid, card)
+		validIndexes = append(validIndexes, i)
+	}
+
+	if partial {
+		for k, card := range valid {
+			if err := h.db.ImportCards(userID, []*models.Card{card}); err != nil {
+				log.Error("Failed to create card", err)
+				resp.Results[validIndexes[k]].Error = "failed to create card"
+				resp.Results[validIndexes[k]].Merged = false
+			}
+		}
+	} else {
+		if len(valid) < len(req.Cards) {
+			resp.tally()
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(resp)
+			return
+		}
+
+		if err := h.db.ImportCards(userID, valid); err != nil {
+			log.Error("Failed to create cards", err)
+			http.Error(w, "Failed to create cards", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	for k, card := range valid {
+		if resp.Results[validIndexes[k]].Error == "" {
+			resp.Results[validIndexes[k]].ID = card.ID
+		}
+	}
+	resp.tally()
+
+	log.Info("Cards created in bulk", "deck_id", deckID, "created", resp.Created, "merged", resp.Merged, "failed", resp.Failed)
+
+	status := http.StatusCreated
+	if resp.Failed > 0 {
+		status = http.StatusOK
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (resp *bulkCreateCardsResponse) tally() {
+	resp.Created, resp.Merged, resp.Failed = 0, 0, 0
+	for _, result := range resp.Results {
+		switch {
+		case result.Error != "":
+			resp.Failed++
+		case result.Merged:
+			resp.Merged++
+		case result.ID > 0:
+			resp.Created++
+		}
+	}
+}
@@ -2,25 +2,46 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 
+	"github.com/dmltdev/flashcards/internal/auth"
 	"github.com/dmltdev/flashcards/internal/database"
 	"github.com/dmltdev/flashcards/internal/logger"
 	"github.com/dmltdev/flashcards/internal/models"
+	"github.com/dmltdev/flashcards/internal/storage"
 )
 
 type Handler struct {
-	db *database.DB
+	db      *database.DB
+	storage storage.Storage
+	tokens  *auth.TokenIssuer
+	oidc    *auth.OIDCProvider
 }
 
-func NewHandler(db *database.DB) *Handler {
-	return &Handler{db: db}
+// NewHandler returns the handler of every route. oidc is nil when no OIDC
+// provider is configured.
+func NewHandler(db *database.DB, storage storage.Storage, tokens *auth.TokenIssuer, oidc *auth.OIDCProvider) *Handler {
+	return &Handler{db: db, storage: storage, tokens: tokens, oidc: oidc}
 }
 
 var log = logger.Default()
 
+const (
+	onDuplicateReject = "reject"
+	onDuplicateMerge  = "merge"
+)
+
+type duplicateCardResponse struct {
+	Error        string       `json:"error"`
+	ExistingCard *models.Card `json:"existing_card"`
+}
+
 func (h *Handler) CreateDeck(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
 	var deck models.Deck
 	if err := json.NewDecoder(r.Body).Decode(&deck); err != nil {
 		log.Error("Invalid JSON", err)
@@ -28,18 +49,47 @@ func (h *Handler) CreateDeck(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	deck.UserID = userID
+	if deck.Kind == "" {
+		deck.Kind = models.DeckKindNormal
+	}
+
 	if err := deck.Validate(); err != nil {
 		log.Error("Invalid deck", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	if deck.ParentID != nil {
+		if _, err := h.db.GetDeck(userID, *deck.ParentID); err != nil {
+			log.Error("Failed to get parent deck", err)
+			http.Error(w, "Parent deck not found", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if deck.PresetID != nil {
+		if _, err := h.db.GetPreset(userID, *deck.PresetID); err != nil {
+			log.Error("Failed to get preset", err)
+			http.Error(w, "Preset not found", http.StatusBadRequest)
+			return
+		}
+	}
+
 	if err := h.db.CreateDeck(&deck); err != nil {
 		log.Error("Failed to create deck", err)
 		http.Error(w, "Failed to create deck", http.StatusInternalServerError)
 		return
 	}
 
+	if deck.Kind == models.DeckKindFiltered {
+		if _, err := h.db.RebuildFilteredDeck(userID, deck.ID); err != nil {
+			log.Error("Failed to build filtered deck", err)
+			http.Error(w, "Failed to build filtered deck", http.StatusInternalServerError)
+			return
+		}
+	}
+
 	log.Info("Deck created", "deck", deck)
 
 	w.Header().Set("Content-Type", "application/json")
@@ -48,7 +98,9 @@ func (h *Handler) CreateDeck(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) GetDecks(w http.ResponseWriter, r *http.Request) {
-	decks, err := h.db.GetAllDecks()
+	userID := requestUser(r).ID
+
+	decks, err := h.db.GetAllDecks(userID)
 	if err != nil {
 		log.Error("Failed to get all decks", err)
 		http.Error(w, "Failed to get all decks", http.StatusInternalServerError)
@@ -60,6 +112,8 @@ func (h *Handler) GetDecks(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) GetDeck(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
 	idStr := r.PathValue("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
@@ -68,7 +122,7 @@ func (h *Handler) GetDeck(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	deck, err := h.db.GetDeck(id)
+	deck, err := h.db.GetDeck(userID, id)
 	if err != nil {
 		log.Error("Failed to get deck", err)
 		http.Error(w, "Deck not found", http.StatusNotFound)
@@ -79,7 +133,106 @@ func (h *Handler) GetDeck(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(deck)
 }
 
+func (h *Handler) UpdateDeck(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Error("Invalid deck ID", err)
+		http.Error(w, "Invalid deck ID", http.StatusBadRequest)
+		return
+	}
+
+	var deck models.Deck
+	if err := json.NewDecoder(r.Body).Decode(&deck); err != nil {
+		log.Error("Invalid JSON", err)
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	deck.ID = id
+	deck.UserID = userID
+
+	// The kind of a deck is fixed when it is created
+	existing, err := h.db.GetDeck(userID, id)
+	if err != nil {
+		log.Error("Failed to get deck", err)
+		http.Error(w, "Deck not found", http.StatusNotFound)
+		return
+	}
+	deck.Kind = existing.Kind
+
+	if existing.Role != models.DeckRoleOwner {
+		http.Error(w, "Only owners can change the deck", http.StatusForbidden)
+		return
+	}
+
+	// The parent and the preset belong to the user owning the deck, so
+	// other owners cannot change them
+	parentChanged := !sameID(deck.ParentID, existing.ParentID)
+	presetChanged := !sameID(deck.PresetID, existing.PresetID)
+	if existing.UserID != userID && (parentChanged || presetChanged) {
+		http.Error(w, "Only the user owning the deck can change its parent or preset", http.StatusForbidden)
+		return
+	}
+
+	if err := deck.Validate(); err != nil {
+		log.Error("Invalid deck", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if deck.ParentID != nil && parentChanged {
+		if _, err := h.db.GetDeck(userID, *deck.ParentID); err != nil {
+			log.Error("Failed to get parent deck", err)
+			http.Error(w, "Parent deck not found", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if deck.PresetID != nil && presetChanged {
+		if _, err := h.db.GetPreset(userID, *deck.PresetID); err != nil {
+			log.Error("Failed to get preset", err)
+			http.Error(w, "Preset not found", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := h.db.UpdateDeck(&deck); err != nil {
+		log.Error("Failed to update deck", err)
+		if errors.Is(err, database.ErrDeckCycle) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Deck not found", http.StatusNotFound)
+		return
+	}
+
+	updated, err := h.db.GetDeck(userID, id)
+	if err != nil {
+		log.Error("Failed to get deck", err)
+		http.Error(w, "Deck not found", http.StatusNotFound)
+		return
+	}
+
+	log.Info("Deck updated", "deck", updated)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// sameID reports whether two optional IDs are equal.
+func sameID(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
 func (h *Handler) CreateCard(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
 	idStr := r.PathValue("id")
 	deckID, err := strconv.Atoi(idStr)
 	if err != nil {
@@ -88,6 +241,12 @@ func (h *Handler) CreateCard(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	onDuplicate, err := parseOnDuplicate(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	var card models.Card
 	if err := json.NewDecoder(r.Body).Decode(&card); err != nil {
 		log.Error("Invalid JSON", err)
@@ -95,15 +254,62 @@ func (h *Handler) CreateCard(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	card.DeckID = deckID
-
-	if err := card.Validate(); err != nil {
+	if err := prepareCard(&card, deckID, r); err != nil {
 		log.Error("Invalid card", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if err := h.db.CreateCard(&card); err != nil {
+	deck, err := h.db.GetDeck(userID, deckID)
+	if err != nil {
+		log.Error("Failed to get deck", err)
+		http.Error(w, "Deck not found", http.StatusNotFound)
+		return
+	}
+	if deck.Kind == models.DeckKindFiltered {
+		http.Error(w, "Cannot add cards to a filtered deck", http.StatusBadRequest)
+		return
+	}
+	if !deck.CanEdit() {
+		http.Error(w, "Cannot add cards to a deck shared with you as a viewer", http.StatusForbidden)
+		return
+	}
+
+	existing, err := h.db.FindDuplicateCard(userID, deckID, card.FrontNormalized)
+	if err != nil {
+		log.Error("Failed to check for duplicate card", err)
+		http.Error(w, "Failed to create card", http.StatusInternalServerError)
+		return
+	}
+
+	if existing != nil {
+		if onDuplicate == onDuplicateReject {
+			log.Warn("Duplicate card rejected", "existing_card_id", existing.ID)
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Location", fmt.Sprintf("/cards/%d", existing.ID))
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(duplicateCardResponse{
+				Error:        "A card with the same front already exists in this deck",
+				ExistingCard: existing,
+			})
+			return
+		}
+
+		merged, err := h.db.MergeCard(userID, existing.ID, &card)
+		if err != nil {
+			log.Error("Failed to merge card", err)
+			http.Error(w, "Failed to merge card", http.StatusInternalServerError)
+			return
+		}
+
+		log.Info("Card merged", "card", merged)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(merged)
+		return
+	}
+
+	if err := h.db.CreateCard(userID, &card); err != nil {
 		log.Error("Failed to create card", err)
 		http.Error(w, "Failed to create card", http.StatusInternalServerError)
 		return
@@ -113,10 +319,82 @@ func (h *Handler) CreateCard(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(card)
+	json.NewEncoder(w).Encode(createCardResponse{
+		Card:     &card,
+		Warnings: h.nearDuplicateWarnings(userID, &card),
+	})
+}
+
+// prepareCard validates a new card for the deck and renders its content.
+func prepareCard(card *models.Card, deckID int, r *http.Request) error {
+	var err error
+
+	card.DeckID = deckID
+	card.UpdatedBy = requestAuthor(r)
+
+	card.Tags, err = models.NormalizeTags(card.Tags)
+	if err != nil {
+		return err
+	}
+
+	if err := card.Validate(); err != nil {
+		return err
+	}
+
+	return card.Render()
+}
+
+// parseOnDuplicate reads the on_duplicate query parameter, which decides
+// whether a card duplicating an existing one is rejected or merged into it.
+func parseOnDuplicate(r *http.Request) (string, error) {
+	onDuplicate := r.URL.Query().Get("on_duplicate")
+	if onDuplicate == "" {
+		return onDuplicateReject, nil
+	}
+	if onDuplicate != onDuplicateReject && onDuplicate != onDuplicateMerge {
+		return "", errors.New("on_duplicate must be one of reject, merge")
+	}
+	return onDuplicate, nil
+}
+
+func (h *Handler) GetCards(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	idStr := r.PathValue("id")
+	deckID, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Error("Invalid deck ID", err)
+		http.Error(w, "Invalid deck ID", http.StatusBadRequest)
+		return
+	}
+
+	filter, err := parseTagFilter(r)
+	if err != nil {
+		log.Error("Invalid tags filter", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.db.GetDeck(userID, deckID); err != nil {
+		log.Error("Failed to get deck", err)
+		http.Error(w, "Deck not found", http.StatusNotFound)
+		return
+	}
+
+	cards, err := h.db.GetCardsByDeck(userID, deckID, filter)
+	if err != nil {
+		log.Error("Failed to get cards", err)
+		http.Error(w, "Failed to get cards", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cards)
 }
 
 func (h *Handler) GetNextCard(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
 	idStr := r.PathValue("id")
 	deckID, err := strconv.Atoi(idStr)
 	if err != nil {
@@ -124,10 +402,28 @@ func (h *Handler) GetNextCard(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	card, err := h.db.GetNextDueCard(deckID)
+	filter, err := parseTagFilter(r)
+	if err != nil {
+		log.Error("Invalid tags filter", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	options, err := h.db.GetDeckOptions(userID, deckID)
+	if err != nil {
+		log.Error("Failed to get deck options", err)
+		http.Error(w, "Deck not found", http.StatusNotFound)
+		return
+	}
+
+	card, err := h.db.GetNextDueCard(userID, deckID, filter, *options)
 
 	if err != nil {
 		log.Error("Failed to get cards", err)
+		if errors.Is(err, database.ErrNoDueCard) {
+			http.Error(w, "No card is due", http.StatusNotFound)
+			return
+		}
 		http.Error(w, "Failed to get cards", http.StatusInternalServerError)
 		return
 	}
@@ -137,3 +433,160 @@ func (h *Handler) GetNextCard(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(card)
 }
+
+func (h *Handler) GetCard(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Error("Invalid card ID", err)
+		http.Error(w, "Invalid card ID", http.StatusBadRequest)
+		return
+	}
+
+	card, err := h.db.GetCard(userID, id)
+	if err != nil {
+		log.Error("Failed to get card", err)
+		http.Error(w, "Card not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(card)
+}
+
+func (h *Handler) UpdateCard(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Error("Invalid card ID", err)
+		http.Error(w, "Invalid card ID", http.StatusBadRequest)
+		return
+	}
+
+	existing, err := h.db.GetCard(userID, id)
+	if err != nil {
+		log.Error("Failed to get card", err)
+		http.Error(w, "Card not found", http.StatusNotFound)
+		return
+	}
+
+	deck, err := h.db.GetDeck(userID, existing.DeckID)
+	if err != nil {
+		log.Error("Failed to get deck", err)
+		http.Error(w, "Card not found", http.StatusNotFound)
+		return
+	}
+	if !deck.CanEdit() {
+		http.Error(w, "Cannot edit cards of a deck shared with you as a viewer", http.StatusForbidden)
+		return
+	}
+
+	var card models.Card
+	if err := json.NewDecoder(r.Body).Decode(&card); err != nil {
+		log.Error("Invalid JSON", err)
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	card.ID = existing.ID
+	card.DeckID = existing.DeckID
+	card.Tags = existing.Tags
+	card.Suspended = existing.Suspended
+	card.CreatedAt = existing.CreatedAt
+	card.UpdatedBy = requestAuthor(r)
+
+	if err := card.Validate(); err != nil {
+		log.Error("Invalid card", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.saveCard(userID, &card); err != nil {
+		log.Error("Failed to update card", err)
+		if errors.Is(err, database.ErrCardNotFound) {
+			http.Error(w, "Card not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to update card", http.StatusInternalServerError)
+		return
+	}
+
+	log.Info("Card updated", "card", card)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(card)
+}
+
+func (h *Handler) SuspendCard(w http.ResponseWriter, r *http.Request) {
+	h.setCardSuspended(w, r, true)
+}
+
+func (h *Handler) UnsuspendCard(w http.ResponseWriter, r *http.Request) {
+	h.setCardSuspended(w, r, false)
+}
+
+func (h *Handler) setCardSuspended(w http.ResponseWriter, r *http.Request, suspended bool) {
+	userID := requestUser(r).ID
+
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Error("Invalid card ID", err)
+		http.Error(w, "Invalid card ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.SetCardSuspended(userID, id, suspended); err != nil {
+		log.Error("Failed to suspend card", err)
+		if errors.Is(err, database.ErrCardNotFound) {
+			http.Error(w, "Card not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to suspend card", http.StatusInternalServerError)
+		return
+	}
+
+	card, err := h.db.GetCard(userID, id)
+	if err != nil {
+		log.Error("Failed to get card", err)
+		http.Error(w, "Card not found", http.StatusNotFound)
+		return
+	}
+
+	log.Info("Card suspension changed", "card_id", id, "suspended", suspended)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(card)
+}
+
+func (h *Handler) GetDuplicates(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	idStr := r.PathValue("id")
+	deckID, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Error("Invalid deck ID", err)
+		http.Error(w, "Invalid deck ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.db.GetDeck(userID, deckID); err != nil {
+		log.Error("Failed to get deck", err)
+		http.Error(w, "Deck not found", http.StatusNotFound)
+		return
+	}
+
+	groups, err := h.db.GetDuplicateGroups(userID, deckID)
+	if err != nil {
+		log.Error("Failed to get duplicate groups", err)
+		http.Error(w, "Failed to get duplicate groups", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(groups)
+}
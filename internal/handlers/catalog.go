@@ -0,0 +1,332 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dmltdev/flashcards/internal/database"
+	"github.com/dmltdev/flashcards/internal/models"
+)
+
+const (
+	defaultCatalogLimit = 20
+	maxCatalogLimit     = 100
+)
+
+// PublishDeck publishes the current content of a deck to the catalog as a
+// new version. Subscribers can then pull the version into their copies.
+func (h *Handler) PublishDeck(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	idStr := r.PathValue("id")
+	deckID, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Error("Invalid deck ID", err)
+		http.Error(w, "Invalid deck ID", http.StatusBadRequest)
+		return
+	}
+
+	var opts models.PublishOptions
+	if err := json.NewDecoder(r.Body).Decode(&opts); err != nil && !errors.Is(err, io.EOF) {
+		log.Error("Invalid JSON", err)
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	opts.Name = strings.TrimSpace(opts.Name)
+	opts.Description = strings.TrimSpace(opts.Description)
+	opts.Notes = strings.TrimSpace(opts.Notes)
+
+	if err := opts.Validate(); err != nil {
+		log.Error("Invalid publish options", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	deck, err := h.db.GetDeck(userID, deckID)
+	if err != nil {
+		log.Error("Failed to get deck", err)
+		http.Error(w, "Deck not found", http.StatusNotFound)
+		return
+	}
+	if deck.Role != models.DeckRoleOwner {
+		http.Error(w, "Only owners can publish the deck", http.StatusForbidden)
+		return
+	}
+	if deck.Kind == models.DeckKindFiltered {
+		http.Error(w, "Cannot publish a filtered deck", http.StatusBadRequest)
+		return
+	}
+
+	publication, err := h.db.PublishDeck(userID, deckID, opts)
+	if err != nil {
+		log.Error("Failed to publish deck", err)
+		http.Error(w, "Failed to publish deck", http.StatusInternalServerError)
+		return
+	}
+
+	log.Info("Deck published", "deck_id", deckID, "publication_id", publication.ID, "version", publication.Version)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(publication)
+}
+
+// GetCatalog lists the published decks. It is public, so it does not depend
+// on the user.
+func (h *Handler) GetCatalog(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+
+	limit, offset, err := parsePagination(r, defaultCatalogLimit, maxCatalogLimit)
+	if err != nil {
+		log.Error("Invalid pagination", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	catalog, err := h.db.GetCatalog(q, limit, offset)
+	if err != nil {
+		log.Error("Failed to get catalog", err)
+		http.Error(w, "Failed to get catalog", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(catalog)
+}
+
+func (h *Handler) GetPublication(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Error("Invalid publication ID", err)
+		http.Error(w, "Invalid publication ID", http.StatusBadRequest)
+		return
+	}
+
+	publication, err := h.db.GetPublication(id)
+	if err != nil {
+		log.Error("Failed to get publication", err)
+		http.Error(w, "Publication not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(publication)
+}
+
+func (h *Handler) UnpublishDeck(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Error("Invalid publication ID", err)
+		http.Error(w, "Invalid publication ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.UnpublishDeck(userID, id); err != nil {
+		log.Error("Failed to unpublish deck", err)
+		http.Error(w, "Publication not found", http.StatusNotFound)
+		return
+	}
+
+	log.Info("Deck unpublished", "publication_id", id)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Subscribe gives the user their own copy of a published deck to study.
+func (h *Handler) Subscribe(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Error("Invalid publication ID", err)
+		http.Error(w, "Invalid publication ID", http.StatusBadRequest)
+		return
+	}
+
+	publication, err := h.db.GetPublication(id)
+	if err != nil {
+		log.Error("Failed to get publication", err)
+		http.Error(w, "Publication not found", http.StatusNotFound)
+		return
+	}
+
+	cards, err := renderPublishedCards(publication.Content, requestAuthor(r))
+	if err != nil {
+		log.Error("Failed to render card", err)
+		http.Error(w, "Failed to subscribe", http.StatusInternalServerError)
+		return
+	}
+
+	subscription, err := h.db.Subscribe(userID, publication, cards)
+	if err != nil {
+		log.Error("Failed to subscribe", err)
+		if errors.Is(err, database.ErrAlreadySubscribed) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to subscribe", http.StatusInternalServerError)
+		return
+	}
+
+	log.Info("Subscribed to deck", "publication_id", id, "deck_id", subscription.DeckID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(subscription)
+}
+
+// renderPublishedCards turns the cards of a published version into cards to
+// store, keeping the IDs of the published cards.
+func renderPublishedCards(content *models.SnapshotContent, author string) ([]*models.Card, error) {
+	cards := make([]*models.Card, len(content.Cards))
+	for i, c := range content.Cards {
+		card := &models.Card{
+			ID:        c.ID,
+			Front:     c.Front,
+			Back:      c.Back,
+			Format:    c.Format,
+			Tags:      c.Tags,
+			UpdatedBy: author,
+		}
+		if err := card.Render(); err != nil {
+			return nil, err
+		}
+		cards[i] = card
+	}
+	return cards, nil
+}
+
+func (h *Handler) GetSubscriptions(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	subscriptions, err := h.db.GetSubscriptions(userID)
+	if err != nil {
+		log.Error("Failed to get subscriptions", err)
+		http.Error(w, "Failed to get subscriptions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(subscriptions)
+}
+
+// GetSubscriptionUpdates lists the changes the publisher made since the
+// version the subscriber's copy was last updated to.
+func (h *Handler) GetSubscriptionUpdates(w http.ResponseWriter, r *http.Request) {
+	subscription, ok := h.loadSubscription(w, r)
+	if !ok {
+		return
+	}
+
+	from, err := h.db.GetPublicationVersion(subscription.PublicationID, subscription.Version)
+	if err != nil {
+		log.Error("Failed to get publication version", err)
+		http.Error(w, "Failed to get subscription updates", http.StatusInternalServerError)
+		return
+	}
+	to, err := h.db.GetPublicationVersion(subscription.PublicationID, subscription.LatestVersion)
+	if err != nil {
+		log.Error("Failed to get publication version", err)
+		http.Error(w, "Failed to get subscription updates", http.StatusInternalServerError)
+		return
+	}
+
+	diff := models.DiffSnapshots(from, to)
+	diff.From = subscription.Version
+	diff.To = &subscription.LatestVersion
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(diff)
+}
+
+// UpdateSubscription pulls the latest version of a publication into the
+// subscriber's copy. Review history and scheduling are kept for every card
+// that remains.
+func (h *Handler) UpdateSubscription(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	subscription, ok := h.loadSubscription(w, r)
+	if !ok {
+		return
+	}
+
+	content, err := h.db.GetPublicationVersion(subscription.PublicationID, subscription.LatestVersion)
+	if err != nil {
+		log.Error("Failed to get publication version", err)
+		http.Error(w, "Failed to update subscription", http.StatusInternalServerError)
+		return
+	}
+
+	cards, err := renderPublishedCards(content, requestAuthor(r))
+	if err != nil {
+		log.Error("Failed to render card", err)
+		http.Error(w, "Failed to update subscription", http.StatusInternalServerError)
+		return
+	}
+
+	update, err := h.db.UpdateSubscription(userID, subscription.ID, subscription.LatestVersion, cards)
+	if err != nil {
+		log.Error("Failed to update subscription", err)
+		http.Error(w, "Failed to update subscription", http.StatusInternalServerError)
+		return
+	}
+
+	log.Info("Subscription updated", "subscription_id", subscription.ID, "update", update)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(update)
+}
+
+func (h *Handler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Error("Invalid subscription ID", err)
+		http.Error(w, "Invalid subscription ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.Unsubscribe(userID, id); err != nil {
+		log.Error("Failed to unsubscribe", err)
+		http.Error(w, "Subscription not found", http.StatusNotFound)
+		return
+	}
+
+	log.Info("Unsubscribed from deck", "subscription_id", id)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// loadSubscription loads the subscription named by the request path, writing
+// the error response if that fails.
+func (h *Handler) loadSubscription(w http.ResponseWriter, r *http.Request) (*models.Subscription, bool) {
+	userID := requestUser(r).ID
+
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Error("Invalid subscription ID", err)
+		http.Error(w, "Invalid subscription ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	subscription, err := h.db.GetSubscription(userID, id)
+	if err != nil {
+		log.Error("Failed to get subscription", err)
+		http.Error(w, "Subscription not found", http.StatusNotFound)
+		return nil, false
+	}
+	return subscription, true
+}
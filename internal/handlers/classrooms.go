@@ -0,0 +1,528 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dmltdev/flashcards/internal/database"
+	"github.com/dmltdev/flashcards/internal/models"
+)
+
+const defaultProgressRetentionDays = 30
+
+type joinClassroomRequest struct {
+	JoinCode string `json:"join_code"`
+}
+
+// newJoinCode returns a random code students use to join a classroom.
+func newJoinCode() (string, error) {
+	b := make([]byte, 5)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.EncodeToString(b), nil
+}
+
+// CreateClassroom creates a classroom taught by the user.
+func (h *Handler) CreateClassroom(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	var classroom models.Classroom
+	if err := json.NewDecoder(r.Body).Decode(&classroom); err != nil {
+		log.Error("Invalid JSON", err)
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	classroom.Name = strings.TrimSpace(classroom.Name)
+	classroom.Description = strings.TrimSpace(classroom.Description)
+
+	if err := classroom.Validate(); err != nil {
+		log.Error("Invalid classroom", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	joinCode, err := newJoinCode()
+	if err != nil {
+		log.Error("Failed to generate join code", err)
+		http.Error(w, "Failed to create classroom", http.StatusInternalServerError)
+		return
+	}
+	classroom.JoinCode = joinCode
+
+	if err := h.db.CreateClassroom(userID, &classroom); err != nil {
+		log.Error("Failed to create classroom", err)
+		http.Error(w, "Failed to create classroom", http.StatusInternalServerError)
+		return
+	}
+
+	log.Info("Classroom created", "classroom_id", classroom.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(classroom)
+}
+
+func (h *Handler) GetClassrooms(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	classrooms, err := h.db.GetClassrooms(userID)
+	if err != nil {
+		log.Error("Failed to get classrooms", err)
+		http.Error(w, "Failed to get classrooms", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(classrooms)
+}
+
+// GetClassroom returns a classroom of the user. Teachers also get its
+// members.
+func (h *Handler) GetClassroom(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	classroom, ok := h.loadClassroom(w, r)
+	if !ok {
+		return
+	}
+
+	if classroom.Role == models.ClassroomRoleTeacher {
+		members, err := h.db.GetClassroomMembers(userID, classroom.ID)
+		if err != nil {
+			log.Error("Failed to get classroom members", err)
+			http.Error(w, "Failed to get classroom", http.StatusInternalServerError)
+			return
+		}
+		classroom.Members = members
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(classroom)
+}
+
+func (h *Handler) UpdateClassroom(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	existing, ok := h.loadTaughtClassroom(w, r)
+	if !ok {
+		return
+	}
+
+	var classroom models.Classroom
+	if err := json.NewDecoder(r.Body).Decode(&classroom); err != nil {
+		log.Error("Invalid JSON", err)
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	existing.Name = strings.TrimSpace(classroom.Name)
+	existing.Description = strings.TrimSpace(classroom.Description)
+
+	if err := existing.Validate(); err != nil {
+		log.Error("Invalid classroom", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.UpdateClassroom(userID, existing); err != nil {
+		log.Error("Failed to update classroom", err)
+		http.Error(w, "Classroom not found", http.StatusNotFound)
+		return
+	}
+
+	log.Info("Classroom updated", "classroom_id", existing.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(existing)
+}
+
+func (h *Handler) DeleteClassroom(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	classroom, ok := h.loadTaughtClassroom(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.db.DeleteClassroom(userID, classroom.ID); err != nil {
+		log.Error("Failed to delete classroom", err)
+		http.Error(w, "Classroom not found", http.StatusNotFound)
+		return
+	}
+
+	log.Info("Classroom deleted", "classroom_id", classroom.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// JoinClassroom adds the user to a classroom as a student with the join code
+// handed out by its teachers.
+func (h *Handler) JoinClassroom(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	var req joinClassroomRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Error("Invalid JSON", err)
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	joinCode := strings.ToUpper(strings.TrimSpace(req.JoinCode))
+	if joinCode == "" {
+		http.Error(w, "join_code cannot be empty", http.StatusBadRequest)
+		return
+	}
+
+	id, err := h.db.JoinClassroom(userID, joinCode)
+	if err != nil {
+		log.Error("Failed to join classroom", err)
+		if errors.Is(err, database.ErrInvalidJoinCode) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to join classroom", http.StatusInternalServerError)
+		return
+	}
+
+	classroom, err := h.db.GetClassroom(userID, id)
+	if err != nil {
+		log.Error("Failed to get classroom", err)
+		http.Error(w, "Classroom not found", http.StatusNotFound)
+		return
+	}
+
+	log.Info("Classroom joined", "classroom_id", id, "user_id", userID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(classroom)
+}
+
+// SetClassroomMember adds the user with the given email to a classroom, or
+// changes their role if they are already a member.
+func (h *Handler) SetClassroomMember(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	classroom, ok := h.loadTaughtClassroom(w, r)
+	if !ok {
+		return
+	}
+
+	var member models.ClassroomMember
+	if err := json.NewDecoder(r.Body).Decode(&member); err != nil {
+		log.Error("Invalid JSON", err)
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	member.ClassroomID = classroom.ID
+	member.Email = strings.ToLower(strings.TrimSpace(member.Email))
+
+	if err := member.Validate(); err != nil {
+		log.Error("Invalid classroom member", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.SetClassroomMember(userID, &member); err != nil {
+		log.Error("Failed to set classroom member", err)
+		switch {
+		case errors.Is(err, database.ErrUserNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, database.ErrLastTeacher):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, "Failed to set classroom member", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	log.Info("Classroom member set", "classroom_id", classroom.ID, "user_id", member.UserID, "role", member.Role)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(member)
+}
+
+// RemoveClassroomMember removes a member from a classroom. Teachers can
+// remove anyone and every member can leave.
+func (h *Handler) RemoveClassroomMember(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	idStr := r.PathValue("id")
+	classroomID, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Error("Invalid classroom ID", err)
+		http.Error(w, "Invalid classroom ID", http.StatusBadRequest)
+		return
+	}
+
+	targetStr := r.PathValue("user_id")
+	targetID, err := strconv.Atoi(targetStr)
+	if err != nil {
+		log.Error("Invalid user ID", err)
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.RemoveClassroomMember(userID, classroomID, targetID); err != nil {
+		log.Error("Failed to remove classroom member", err)
+		if errors.Is(err, database.ErrLastTeacher) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Classroom member not found", http.StatusNotFound)
+		return
+	}
+
+	log.Info("Classroom member removed", "classroom_id", classroomID, "user_id", targetID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CreateAssignment assigns a deck the teacher owns to a classroom. Members
+// of the classroom can study the deck and its subdecks from then on.
+func (h *Handler) CreateAssignment(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	classroom, ok := h.loadTaughtClassroom(w, r)
+	if !ok {
+		return
+	}
+
+	var assignment models.Assignment
+	if err := json.NewDecoder(r.Body).Decode(&assignment); err != nil {
+		log.Error("Invalid JSON", err)
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	assignment.ClassroomID = classroom.ID
+
+	if err := assignment.Validate(); err != nil {
+		log.Error("Invalid assignment", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	deck, err := h.db.GetDeck(userID, assignment.DeckID)
+	if err != nil {
+		log.Error("Failed to get deck", err)
+		http.Error(w, "Deck not found", http.StatusBadRequest)
+		return
+	}
+	if deck.Role != models.DeckRoleOwner {
+		http.Error(w, "Only owners can assign the deck", http.StatusForbidden)
+		return
+	}
+	if deck.Kind == models.DeckKindFiltered {
+		http.Error(w, "Cannot assign a filtered deck", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.CreateAssignment(userID, &assignment); err != nil {
+		log.Error("Failed to create assignment", err)
+		if errors.Is(err, database.ErrAlreadyAssigned) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to create assignment", http.StatusInternalServerError)
+		return
+	}
+
+	log.Info("Assignment created", "classroom_id", classroom.ID, "deck_id", assignment.DeckID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(assignment)
+}
+
+func (h *Handler) GetAssignments(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	classroom, ok := h.loadClassroom(w, r)
+	if !ok {
+		return
+	}
+
+	assignments, err := h.db.GetAssignments(userID, classroom.ID)
+	if err != nil {
+		log.Error("Failed to get assignments", err)
+		http.Error(w, "Failed to get assignments", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(assignments)
+}
+
+// UpdateAssignment changes the due date and target mastery of an
+// assignment. The assigned deck cannot be changed.
+func (h *Handler) UpdateAssignment(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	classroom, ok := h.loadTaughtClassroom(w, r)
+	if !ok {
+		return
+	}
+
+	assignmentID, err := strconv.Atoi(r.PathValue("assignment_id"))
+	if err != nil {
+		log.Error("Invalid assignment ID", err)
+		http.Error(w, "Invalid assignment ID", http.StatusBadRequest)
+		return
+	}
+
+	var assignment models.Assignment
+	if err := json.NewDecoder(r.Body).Decode(&assignment); err != nil {
+		log.Error("Invalid JSON", err)
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	assignment.ID = assignmentID
+	assignment.ClassroomID = classroom.ID
+	if assignment.TargetMastery != nil && (*assignment.TargetMastery <= 0 || *assignment.TargetMastery > 1) {
+		http.Error(w, "target_mastery must be greater than 0 and at most 1", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.UpdateAssignment(userID, &assignment); err != nil {
+		log.Error("Failed to update assignment", err)
+		http.Error(w, "Assignment not found", http.StatusNotFound)
+		return
+	}
+
+	log.Info("Assignment updated", "classroom_id", classroom.ID, "assignment_id", assignmentID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(assignment)
+}
+
+func (h *Handler) DeleteAssignment(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	classroom, ok := h.loadTaughtClassroom(w, r)
+	if !ok {
+		return
+	}
+
+	assignmentID, err := strconv.Atoi(r.PathValue("assignment_id"))
+	if err != nil {
+		log.Error("Invalid assignment ID", err)
+		http.Error(w, "Invalid assignment ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.DeleteAssignment(userID, classroom.ID, assignmentID); err != nil {
+		log.Error("Failed to delete assignment", err)
+		http.Error(w, "Assignment not found", http.StatusNotFound)
+		return
+	}
+
+	log.Info("Assignment deleted", "classroom_id", classroom.ID, "assignment_id", assignmentID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetClassroomProgress reports the progress of every student of a classroom
+// on each assignment.
+func (h *Handler) GetClassroomProgress(w http.ResponseWriter, r *http.Request) {
+	classroom, ok := h.loadTaughtClassroom(w, r)
+	if !ok {
+		return
+	}
+
+	h.writeProgress(w, r, classroom, nil)
+}
+
+// GetStudentProgress reports the progress of a student on each assignment
+// of a classroom. Students can only see their own progress.
+func (h *Handler) GetStudentProgress(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	classroom, ok := h.loadClassroom(w, r)
+	if !ok {
+		return
+	}
+
+	studentID, err := strconv.Atoi(r.PathValue("user_id"))
+	if err != nil {
+		log.Error("Invalid user ID", err)
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	if classroom.Role != models.ClassroomRoleTeacher && studentID != userID {
+		http.Error(w, "Students can only see their own progress", http.StatusForbidden)
+		return
+	}
+
+	h.writeProgress(w, r, classroom, &studentID)
+}
+
+// writeProgress responds with the assignment progress of the students of a
+// classroom. The days query parameter sets the retention window.
+func (h *Handler) writeProgress(w http.ResponseWriter, r *http.Request, classroom *models.Classroom, studentID *int) {
+	userID := requestUser(r).ID
+
+	days := defaultProgressRetentionDays
+	if daysStr := r.URL.Query().Get("days"); daysStr != "" {
+		d, err := strconv.Atoi(daysStr)
+		if err != nil || d <= 0 || d > maxStatsDays {
+			http.Error(w, "days must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		days = d
+	}
+
+	progress, err := h.db.GetAssignmentProgress(userID, classroom.ID, studentID, days)
+	if err != nil {
+		log.Error("Failed to get assignment progress", err)
+		http.Error(w, "Failed to get progress", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(progress)
+}
+
+// loadClassroom loads the classroom named by the request path as seen by the
+// user, writing the error response if that fails.
+func (h *Handler) loadClassroom(w http.ResponseWriter, r *http.Request) (*models.Classroom, bool) {
+	userID := requestUser(r).ID
+
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Error("Invalid classroom ID", err)
+		http.Error(w, "Invalid classroom ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	classroom, err := h.db.GetClassroom(userID, id)
+	if err != nil {
+		log.Error("Failed to get classroom", err)
+		http.Error(w, "Classroom not found", http.StatusNotFound)
+		return nil, false
+	}
+	return classroom, true
+}
+
+// loadTaughtClassroom is loadClassroom for the actions reserved to teachers.
+func (h *Handler) loadTaughtClassroom(w http.ResponseWriter, r *http.Request) (*models.Classroom, bool) {
+	classroom, ok := h.loadClassroom(w, r)
+	if !ok {
+		return nil, false
+	}
+	if classroom.Role != models.ClassroomRoleTeacher {
+		http.Error(w, "Only teachers can do this", http.StatusForbidden)
+		return nil, false
+	}
+	return classroom, true
+}
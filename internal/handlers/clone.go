@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/dmltdev/flashcards/internal/database"
+	"github.com/dmltdev/flashcards/internal/models"
+)
+
+// CloneDeck deep-copies a deck with its cards, and optionally their tags and
+// the subdecks, into a new deck. The request body is optional.
+func (h *Handler) CloneDeck(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Error("Invalid deck ID", err)
+		http.Error(w, "Invalid deck ID", http.StatusBadRequest)
+		return
+	}
+
+	var opts models.DeckCloneOptions
+	if err := json.NewDecoder(r.Body).Decode(&opts); err != nil && !errors.Is(err, io.EOF) {
+		log.Error("Invalid JSON", err)
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := opts.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.db.GetDeck(userID, id); err != nil {
+		log.Error("Failed to get deck", err)
+		http.Error(w, "Deck not found", http.StatusNotFound)
+		return
+	}
+
+	// The clone belongs to the user, so it can only be placed in one of the
+	// user's own decks, not in a deck shared with them
+	if opts.ParentID != nil {
+		parent, err := h.db.GetDeck(userID, *opts.ParentID)
+		if err != nil {
+			log.Error("Failed to get parent deck", err)
+			http.Error(w, "Parent deck not found", http.StatusBadRequest)
+			return
+		}
+		if parent.UserID != userID {
+			http.Error(w, "Cannot clone into a deck shared with you", http.StatusForbidden)
+			return
+		}
+	}
+
+	cloneID, err := h.db.CloneDeck(userID, id, opts, requestAuthor(r))
+	if err != nil {
+		log.Error("Failed to clone deck", err)
+		if errors.Is(err, database.ErrDeckCycle) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to clone deck", http.StatusInternalServerError)
+		return
+	}
+
+	clone, err := h.db.GetDeck(userID, cloneID)
+	if err != nil {
+		log.Error("Failed to get deck", err)
+		http.Error(w, "Failed to clone deck", http.StatusInternalServerError)
+		return
+	}
+
+	log.Info("Deck cloned", "source_deck_id", id, "deck", clone)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", fmt.Sprintf("/decks/%d", clone.ID))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(clone)
+}
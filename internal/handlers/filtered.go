@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+type filteredDeckResponse struct {
+	DeckID int   `json:"deck_id"`
+	Cards  int64 `json:"cards"`
+}
+
+// RebuildDeck returns the cards of a filtered deck to their home decks and
+// pulls in the cards currently matching its filter.
+func (h *Handler) RebuildDeck(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Error("Invalid deck ID", err)
+		http.Error(w, "Invalid deck ID", http.StatusBadRequest)
+		return
+	}
+
+	pulled, err := h.db.RebuildFilteredDeck(userID, id)
+	if err != nil {
+		log.Error("Failed to rebuild filtered deck", err)
+		http.Error(w, "Filtered deck not found", http.StatusNotFound)
+		return
+	}
+
+	log.Info("Filtered deck rebuilt", "deck_id", id, "cards", pulled)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(filteredDeckResponse{DeckID: id, Cards: pulled})
+}
+
+// EmptyDeck returns all cards of a filtered deck to their home decks.
+func (h *Handler) EmptyDeck(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Error("Invalid deck ID", err)
+		http.Error(w, "Invalid deck ID", http.StatusBadRequest)
+		return
+	}
+
+	returned, err := h.db.EmptyFilteredDeck(userID, id)
+	if err != nil {
+		log.Error("Failed to empty filtered deck", err)
+		http.Error(w, "Filtered deck not found", http.StatusNotFound)
+		return
+	}
+
+	log.Info("Filtered deck emptied", "deck_id", id, "cards", returned)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(filteredDeckResponse{DeckID: id, Cards: returned})
+}
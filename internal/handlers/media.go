@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+
+	"github.com/dmltdev/flashcards/internal/models"
+	"github.com/dmltdev/flashcards/internal/storage"
+)
+
+const maxMediaSize = 10 << 20
+
+var checksumPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// mediaTypes maps the types sniffed by http.DetectContentType to the types
+// media are served with. Audio containers it reports as application or
+// video types are served as audio, since cards only play their sound.
+var mediaTypes = map[string]string{
+	"image/png":       "image/png",
+	"image/jpeg":      "image/jpeg",
+	"image/gif":       "image/gif",
+	"image/webp":      "image/webp",
+	"image/bmp":       "image/bmp",
+	"audio/mpeg":      "audio/mpeg",
+	"audio/wave":      "audio/wav",
+	"audio/aiff":      "audio/aiff",
+	"audio/basic":     "audio/basic",
+	"audio/midi":      "audio/midi",
+	"application/ogg": "audio/ogg",
+	"video/webm":      "audio/webm",
+}
+
+// audioBrands are the major brands of the MP4 files that only hold audio.
+// Other brands are video or images such as HEIC and AVIF.
+var audioBrands = map[string]bool{
+	"M4A ": true,
+	"M4B ": true,
+	"M4P ": true,
+	"F4A ": true,
+	"F4B ": true,
+}
+
+// sniffMediaType returns the type of the media starting with data, or false
+// when it is not a supported image or audio format. MP4 files are only
+// accepted with an audio brand. It also recognizes the formats
+// http.DetectContentType misses: FLAC, and raw AAC and MP3 without an ID3
+// tag.
+func sniffMediaType(data []byte) (string, bool) {
+	if len(data) >= 12 && string(data[4:8]) == "ftyp" {
+		if audioBrands[string(data[8:12])] {
+			return "audio/mp4", true
+		}
+		return "", false
+	}
+	if mimeType, ok := mediaTypes[http.DetectContentType(data)]; ok {
+		return mimeType, true
+	}
+	switch {
+	case bytes.HasPrefix(data, []byte("fLaC")):
+		return "audio/flac", true
+	case len(data) >= 2 && data[0] == 0xFF && data[1]&0xF6 == 0xF0:
+		// ADTS frame header
+		return "audio/aac", true
+	case len(data) >= 2 && data[0] == 0xFF && data[1]&0xE0 == 0xE0:
+		// MPEG audio frame header
+		return "audio/mpeg", true
+	}
+	return "", false
+}
+
+func (h *Handler) UploadMedia(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxMediaSize)
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		log.Error("Invalid upload", err)
+		http.Error(w, "Invalid upload, expected multipart field \"file\" up to 10MB", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	// Sniff the content instead of trusting the client supplied type
+	sniff := make([]byte, 512)
+	n, _ := file.Read(sniff)
+	mimeType, ok := sniffMediaType(sniff[:n])
+	if !ok {
+		log.Warn("Unsupported media type", "mime_type", http.DetectContentType(sniff[:n]))
+		http.Error(w, "Only image and audio files are supported", http.StatusUnsupportedMediaType)
+		return
+	}
+	if _, err := file.Seek(0, 0); err != nil {
+		log.Error("Failed to read upload", err)
+		http.Error(w, "Failed to read upload", http.StatusInternalServerError)
+		return
+	}
+
+	media := models.Media{
+		MimeType: mimeType,
+		Filename: header.Filename,
+	}
+	err = h.db.CreateMedia(&media, func() error {
+		checksum, size, err := h.storage.Put(file)
+		if err != nil {
+			return err
+		}
+		media.Checksum = checksum
+		media.Size = size
+		return nil
+	})
+	if err != nil {
+		log.Error("Failed to create media", err)
+		http.Error(w, "Failed to create media", http.StatusInternalServerError)
+		return
+	}
+	media.URL = "/media/" + media.Checksum
+
+	log.Info("Media uploaded", "media", media)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(media)
+}
+
+func (h *Handler) GetMedia(w http.ResponseWriter, r *http.Request) {
+	checksum := r.PathValue("checksum")
+	if !checksumPattern.MatchString(checksum) {
+		http.Error(w, "Invalid media checksum", http.StatusBadRequest)
+		return
+	}
+
+	media, err := h.db.GetMediaByChecksum(checksum)
+	if err != nil {
+		log.Error("Failed to get media", err)
+		http.Error(w, "Media not found", http.StatusNotFound)
+		return
+	}
+
+	file, err := h.storage.Open(checksum)
+	if err != nil {
+		log.Error("Failed to open media", err)
+		if errors.Is(err, storage.ErrNotFound) {
+			http.Error(w, "Media not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to open media", http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	// Content is addressed by its checksum, so it never changes
+	w.Header().Set("Content-Type", media.MimeType)
+	w.Header().Set("ETag", `"`+media.Checksum+`"`)
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	http.ServeContent(w, r, "", media.CreatedAt, file)
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import "testing"
+
+func TestSniffMediaType(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+		ok   bool
+	}{
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), "image/png", true},
+		{"jpeg", []byte("\xFF\xD8\xFF\xE0\x00\x10JFIF"), "image/jpeg", true},
+		{"mp3 with id3", []byte("ID3\x03\x00\x00\x00\x00\x00\x00"), "audio/mpeg", true},
+		{"mp3 frame", []byte{0xFF, 0xFB, 0x90, 0x64, 0x00, 0x00}, "audio/mpeg", true},
+		{"aac", []byte{0xFF, 0xF1, 0x50, 0x80, 0x00, 0x1F, 0xFC}, "audio/aac", true},
+		{"ogg", []byte("OggS\x00\x02\x00\x00\x00\x00\x00\x00"), "audio/ogg", true},
+		{"wav", []byte("RIFF\x00\x00\x00\x00WAVEfmt "), "audio/wav", true},
+		{"m4a", []byte("\x00\x00\x00\x20ftypM4A \x00\x00\x00\x00M4A mp42isom"), "audio/mp4", true},
+		{"flac", []byte("fLaC\x00\x00\x00\x22"), "audio/flac", true},
+		{"mp4 video", []byte("\x00\x00\x00\x20ftypisom\x00\x00\x02\x00isomiso2avc1mp41"), "", false},
+		{"heic", []byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic"), "", false},
+		{"avif", []byte("\x00\x00\x00\x1cftypavif\x00\x00\x00\x00avifmif1miaf"), "", false},
+		{"svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`), "", false},
+		{"html", []byte("<html><script>alert(1)</script></html>"), "", false},
+		{"pdf", []byte("%PDF-1.7\n"), "", false},
+		{"empty", nil, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := sniffMediaType(tt.data)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("sniffMediaType() = %q, %v, want %q, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
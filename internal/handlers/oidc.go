@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/dmltdev/flashcards/internal/auth"
+	"github.com/dmltdev/flashcards/internal/database"
+)
+
+const (
+	oidcStateCookie = "oidc_state"
+	oidcLoginTTL    = 10 * time.Minute
+)
+
+// OIDCLogin starts a login with the OIDC provider by redirecting to it. The
+// state is also set as a cookie so the callback only completes the login in
+// the browser that started it.
+func (h *Handler) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	login, err := auth.NewOIDCLogin()
+	if err != nil {
+		log.Error("Failed to start OIDC login", err)
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.db.CreateOIDCLogin(auth.HashToken(login.State), login.Nonce, login.Verifier, time.Now().Add(oidcLoginTTL)); err != nil {
+		log.Error("Failed to store OIDC login", err)
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    login.State,
+		Path:     "/auth/oidc",
+		MaxAge:   int(oidcLoginTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, h.oidc.AuthCodeURL(login), http.StatusFound)
+}
+
+// OIDCCallback completes a login with the OIDC provider, provisioning the
+// user on their first login, and responds with a new session's tokens.
+func (h *Handler) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Path: "/auth/oidc", MaxAge: -1})
+
+	if providerErr := query.Get("error"); providerErr != "" {
+		log.Warn("OIDC login failed", "error", providerErr, "description", query.Get("error_description"))
+		http.Error(w, "Login failed: "+providerErr, http.StatusUnauthorized)
+		return
+	}
+
+	state := query.Get("state")
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil || state == "" || cookie.Value != state {
+		http.Error(w, "Invalid login state", http.StatusBadRequest)
+		return
+	}
+
+	nonce, verifier, err := h.db.ConsumeOIDCLogin(auth.HashToken(state))
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidOIDCLogin) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Error("Failed to consume OIDC login", err)
+		http.Error(w, "Failed to log in", http.StatusInternalServerError)
+		return
+	}
+
+	login := &auth.OIDCLogin{State: state, Nonce: nonce, Verifier: verifier}
+	identity, err := h.oidc.Exchange(r.Context(), login, query.Get("code"))
+	if err != nil {
+		log.Error("Failed to complete OIDC login", err)
+		http.Error(w, "Login failed", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := h.db.ProvisionOIDCUser(identity)
+	if err != nil {
+		log.Error("Failed to provision OIDC user", err)
+		if errors.Is(err, database.ErrEmailTaken) {
+			http.Error(w, "An account with this email already exists and cannot be linked to this login", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to log in", http.StatusInternalServerError)
+		return
+	}
+
+	log.Info("User logged in with OIDC", "user_id", user.ID)
+
+	h.issueTokens(w, user, http.StatusOK)
+}
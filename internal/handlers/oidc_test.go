@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dmltdev/flashcards/internal/auth"
+	"github.com/dmltdev/flashcards/internal/database"
+	"github.com/dmltdev/flashcards/internal/models"
+	"github.com/dmltdev/flashcards/internal/testutil/oidctest"
+)
+
+const oidcClientID = "flashcards"
+
+func TestOIDCCallbackRejectsState(t *testing.T) {
+	// The state is checked before the login is looked up, so no database
+	// is needed
+	h := NewHandler(nil, nil, nil, nil)
+
+	tests := []struct {
+		name   string
+		query  string
+		cookie string
+		want   int
+	}{
+		{"expired state cookie", "code=abc&state=s1", "", http.StatusBadRequest},
+		{"state mismatch", "code=abc&state=s1", "s2", http.StatusBadRequest},
+		{"missing state", "code=abc", "s1", http.StatusBadRequest},
+		{"provider error", "error=access_denied&state=s1", "s1", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/auth/oidc/callback?"+tt.query, nil)
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: oidcStateCookie, Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+
+			h.OIDCCallback(w, r)
+
+			if w.Code != tt.want {
+				t.Errorf("OIDCCallback() status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+// testDB connects to the database configured by the DB_* variables, which
+// must be migrated up. Tests using it are skipped unless TEST_DB is set.
+func testDB(t *testing.T) *database.DB {
+	t.Helper()
+	if os.Getenv("TEST_DB") == "" {
+		t.Skip("TEST_DB is not set")
+	}
+	db, err := database.NewConnection()
+	if err != nil {
+		t.Fatalf("failed to connect to database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+type oidcTest struct {
+	h      *Handler
+	db     *database.DB
+	issuer *oidctest.Issuer
+	domain string
+}
+
+func newOIDCTest(t *testing.T) *oidcTest {
+	db := testDB(t)
+	issuer := oidctest.NewIssuer(t, oidcClientID)
+	provider, err := auth.NewOIDCProvider(context.Background(), issuer.Client(), issuer.URL, oidcClientID, "secret", "http://localhost/auth/oidc/callback")
+	if err != nil {
+		t.Fatalf("NewOIDCProvider() error = %v", err)
+	}
+	tokens := auth.NewTokenIssuer([]byte("0123456789abcdef0123456789abcdef"), time.Minute, time.Hour)
+
+	domain := fmt.Sprintf("oidc%d.test", time.Now().UnixNano())
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM users WHERE email LIKE $1`, "%@"+domain)
+	})
+
+	return &oidcTest{h: NewHandler(db, nil, tokens, provider), db: db, issuer: issuer, domain: domain}
+}
+
+func (o *oidcTest) identity(subject string, verified bool) oidctest.Identity {
+	return oidctest.Identity{Subject: subject, Email: subject + "@" + o.domain, EmailVerified: verified, Name: subject}
+}
+
+// login goes through the whole login flow as identity and returns the
+// callback's response.
+func (o *oidcTest) login(t *testing.T, identity oidctest.Identity) *httptest.ResponseRecorder {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	o.h.OIDCLogin(w, httptest.NewRequest(http.MethodGet, "/auth/oidc/login", nil))
+	if w.Code != http.StatusFound {
+		t.Fatalf("OIDCLogin() status = %d, want %d", w.Code, http.StatusFound)
+	}
+
+	code, err := o.issuer.Authorize(w.Header().Get("Location"), identity)
+	if err != nil {
+		t.Fatalf("Authorize() error = %v", err)
+	}
+
+	var cookie *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == oidcStateCookie {
+			cookie = c
+		}
+	}
+	if cookie == nil {
+		t.Fatal("OIDCLogin() set no state cookie")
+	}
+
+	query := url.Values{"code": {code}, "state": {cookie.Value}}
+	r := httptest.NewRequest(http.MethodGet, "/auth/oidc/callback?"+query.Encode(), nil)
+	r.AddCookie(cookie)
+	w = httptest.NewRecorder()
+	o.h.OIDCCallback(w, r)
+	return w
+}
+
+func (o *oidcTest) loggedInUser(t *testing.T, w *httptest.ResponseRecorder) *models.User {
+	t.Helper()
+	if w.Code != http.StatusOK {
+		t.Fatalf("OIDCCallback() status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	var resp tokenResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("invalid token response: %v", err)
+	}
+	if resp.AccessToken == "" || resp.RefreshToken == "" || resp.User == nil {
+		t.Fatalf("token response = %+v, want tokens and a user", resp)
+	}
+	return resp.User
+}
+
+func TestOIDCLoginProvisionsUser(t *testing.T) {
+	o := newOIDCTest(t)
+	identity := o.identity("alice", false)
+
+	user := o.loggedInUser(t, o.login(t, identity))
+	if user.Email != identity.Email {
+		t.Errorf("provisioned user email = %q, want %q", user.Email, identity.Email)
+	}
+
+	again := o.loggedInUser(t, o.login(t, identity))
+	if again.ID != user.ID {
+		t.Errorf("second login user = %d, want the provisioned user %d", again.ID, user.ID)
+	}
+}
+
+func TestOIDCLoginLinksExistingEmail(t *testing.T) {
+	o := newOIDCTest(t)
+
+	existing := &models.User{Email: "bob@" + o.domain, Name: "Bob"}
+	if err := o.db.CreateUser(existing); err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	unverified := o.identity("bob", false)
+	if w := o.login(t, unverified); w.Code != http.StatusConflict {
+		t.Errorf("unverified email login status = %d, want %d", w.Code, http.StatusConflict)
+	}
+
+	user := o.loggedInUser(t, o.login(t, o.identity("bob", true)))
+	if user.ID != existing.ID {
+		t.Errorf("verified email login user = %d, want the existing user %d", user.ID, existing.ID)
+	}
+}
+
+func TestOIDCLoginRefusesPasswordAccount(t *testing.T) {
+	o := newOIDCTest(t)
+
+	existing := &models.User{Email: "carol@" + o.domain, Name: "Carol", PasswordHash: "unused"}
+	if err := o.db.CreateUser(existing); err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	if w := o.login(t, o.identity("carol", true)); w.Code != http.StatusConflict {
+		t.Errorf("verified email login status = %d, want %d", w.Code, http.StatusConflict)
+	}
+}
+
+func TestOIDCCallbackRejectsExpiredLogin(t *testing.T) {
+	o := newOIDCTest(t)
+
+	state := "expired-" + o.domain
+	if err := o.db.CreateOIDCLogin(auth.HashToken(state), "nonce", "verifier", time.Now().Add(-48*time.Hour)); err != nil {
+		t.Fatalf("CreateOIDCLogin() error = %v", err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/auth/oidc/callback?code=abc&state="+url.QueryEscape(state), nil)
+	r.AddCookie(&http.Cookie{Name: oidcStateCookie, Value: state})
+	w := httptest.NewRecorder()
+	o.h.OIDCCallback(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("OIDCCallback() status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
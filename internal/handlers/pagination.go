@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// parsePagination reads the limit and offset query parameters, applying the
+// default limit when absent and capping it at maxLimit.
+func parsePagination(r *http.Request, defaultLimit, maxLimit int) (int, int, error) {
+	params := r.URL.Query()
+
+	limit := defaultLimit
+	if limitStr := params.Get("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l <= 0 {
+			return 0, 0, errors.New("limit must be a positive integer")
+		}
+		limit = min(l, maxLimit)
+	}
+
+	offset := 0
+	if offsetStr := params.Get("offset"); offsetStr != "" {
+		o, err := strconv.Atoi(offsetStr)
+		if err != nil || o < 0 {
+			return 0, 0, errors.New("offset must be a non-negative integer")
+		}
+		offset = o
+	}
+
+	return limit, offset, nil
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/dmltdev/flashcards/internal/models"
+)
+
+func (h *Handler) CreatePreset(w http.ResponseWriter, r *http.Request) {
+	var preset models.Preset
+	if err := json.NewDecoder(r.Body).Decode(&preset); err != nil {
+		log.Error("Invalid JSON", err)
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	preset.UserID = requestUser(r).ID
+
+	if err := preset.Validate(); err != nil {
+		log.Error("Invalid preset", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.CreatePreset(&preset); err != nil {
+		log.Error("Failed to create preset", err)
+		http.Error(w, "Failed to create preset", http.StatusInternalServerError)
+		return
+	}
+
+	log.Info("Preset created", "preset", preset)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(preset)
+}
+
+func (h *Handler) GetPresets(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	presets, err := h.db.GetAllPresets(userID)
+	if err != nil {
+		log.Error("Failed to get all presets", err)
+		http.Error(w, "Failed to get all presets", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(presets)
+}
+
+func (h *Handler) GetPreset(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Error("Invalid preset ID", err)
+		http.Error(w, "Invalid preset ID", http.StatusBadRequest)
+		return
+	}
+
+	preset, err := h.db.GetPreset(userID, id)
+	if err != nil {
+		log.Error("Failed to get preset", err)
+		http.Error(w, "Preset not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(preset)
+}
+
+func (h *Handler) UpdatePreset(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Error("Invalid preset ID", err)
+		http.Error(w, "Invalid preset ID", http.StatusBadRequest)
+		return
+	}
+
+	var preset models.Preset
+	if err := json.NewDecoder(r.Body).Decode(&preset); err != nil {
+		log.Error("Invalid JSON", err)
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	preset.ID = id
+	preset.UserID = requestUser(r).ID
+
+	if err := preset.Validate(); err != nil {
+		log.Error("Invalid preset", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.UpdatePreset(&preset); err != nil {
+		log.Error("Failed to update preset", err)
+		http.Error(w, "Preset not found", http.StatusNotFound)
+		return
+	}
+
+	log.Info("Preset updated", "preset", preset)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(preset)
+}
+
+func (h *Handler) DeletePreset(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Error("Invalid preset ID", err)
+		http.Error(w, "Invalid preset ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.DeletePreset(userID, id); err != nil {
+		log.Error("Failed to delete preset", err)
+		http.Error(w, "Preset not found", http.StatusNotFound)
+		return
+	}
+
+	log.Info("Preset deleted", "preset_id", id)
+
+	w.WriteHeader(http.StatusNoContent)
+}
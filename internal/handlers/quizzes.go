@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/dmltdev/flashcards/internal/database"
+	"github.com/dmltdev/flashcards/internal/models"
+)
+
+// CreateQuiz generates a quiz from a random sample of the cards of a deck
+// and its subdecks.
+func (h *Handler) CreateQuiz(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	idStr := r.PathValue("id")
+	deckID, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Error("Invalid deck ID", err)
+		http.Error(w, "Invalid deck ID", http.StatusBadRequest)
+		return
+	}
+
+	var opts models.QuizOptions
+	if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+		log.Error("Invalid JSON", err)
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := opts.Validate(); err != nil {
+		log.Error("Invalid quiz options", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.db.GetDeck(userID, deckID); err != nil {
+		log.Error("Failed to get deck", err)
+		http.Error(w, "Deck not found", http.StatusNotFound)
+		return
+	}
+
+	quiz, err := h.db.CreateQuiz(userID, deckID, opts)
+	if err != nil {
+		log.Error("Failed to create quiz", err)
+		if errors.Is(err, database.ErrNoQuizCards) || errors.Is(err, database.ErrNotEnoughChoices) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to create quiz", http.StatusInternalServerError)
+		return
+	}
+
+	log.Info("Quiz created", "quiz_id", quiz.ID, "deck_id", deckID, "questions", quiz.QuestionCount)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(quiz)
+}
+
+func (h *Handler) GetQuizzes(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	quizzes, err := h.db.GetQuizzes(userID)
+	if err != nil {
+		log.Error("Failed to get quizzes", err)
+		http.Error(w, "Failed to get quizzes", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(quizzes)
+}
+
+// GetQuiz returns a quiz with its questions, and once it is over its score
+// and the grading of every question.
+func (h *Handler) GetQuiz(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Error("Invalid quiz ID", err)
+		http.Error(w, "Invalid quiz ID", http.StatusBadRequest)
+		return
+	}
+
+	quiz, err := h.db.GetQuiz(userID, id)
+	if err != nil {
+		log.Error("Failed to get quiz", err)
+		http.Error(w, "Quiz not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(quiz)
+}
+
+// AnswerQuizQuestion records the answer to a question of a quiz in progress.
+func (h *Handler) AnswerQuizQuestion(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Error("Invalid quiz ID", err)
+		http.Error(w, "Invalid quiz ID", http.StatusBadRequest)
+		return
+	}
+
+	positionStr := r.PathValue("position")
+	position, err := strconv.Atoi(positionStr)
+	if err != nil {
+		log.Error("Invalid question position", err)
+		http.Error(w, "Invalid question position", http.StatusBadRequest)
+		return
+	}
+
+	var answer models.QuizAnswer
+	if err := json.NewDecoder(r.Body).Decode(&answer); err != nil {
+		log.Error("Invalid JSON", err)
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	question, err := h.db.AnswerQuizQuestion(userID, id, position, answer)
+	if err != nil {
+		log.Error("Failed to answer quiz question", err)
+		switch {
+		case errors.Is(err, database.ErrQuizOver):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, database.ErrInvalidAnswer):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, "Quiz question not found", http.StatusNotFound)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(question)
+}
+
+// SubmitQuiz ends a quiz in progress and returns its results.
+func (h *Handler) SubmitQuiz(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Error("Invalid quiz ID", err)
+		http.Error(w, "Invalid quiz ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.SubmitQuiz(userID, id); err != nil {
+		log.Error("Failed to submit quiz", err)
+		if errors.Is(err, database.ErrQuizOver) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Quiz not found", http.StatusNotFound)
+		return
+	}
+
+	quiz, err := h.db.GetQuiz(userID, id)
+	if err != nil {
+		log.Error("Failed to get quiz", err)
+		http.Error(w, "Failed to get quiz", http.StatusInternalServerError)
+		return
+	}
+
+	log.Info("Quiz submitted", "quiz_id", quiz.ID, "status", quiz.Status)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(quiz)
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/dmltdev/flashcards/internal/diff"
+	"github.com/dmltdev/flashcards/internal/models"
+	"github.com/dmltdev/flashcards/internal/tagexpr"
+)
+
+type replaceRequest struct {
+	Find       string   `json:"find"`
+	Replace    string   `json:"replace"`
+	Regex      bool     `json:"regex"`
+	IgnoreCase bool     `json:"ignore_case"`
+	Fields     []string `json:"fields"`
+	Tags       string   `json:"tags"`
+	DryRun     bool     `json:"dry_run"`
+}
+
+type replacePreview struct {
+	CardID    int           `json:"card_id"`
+	Front     string        `json:"front"`
+	Back      string        `json:"back"`
+	FrontDiff []diff.Change `json:"front_diff,omitempty"`
+	BackDiff  []diff.Change `json:"back_diff,omitempty"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// replaceResponse counts the cards whose replaced content is valid in
+// Matched and the others, which are left unchanged, in Invalid.
+type replaceResponse struct {
+	DryRun  bool             `json:"dry_run"`
+	Matched int              `json:"matched"`
+	Invalid int              `json:"invalid"`
+	Updated int              `json:"updated"`
+	Cards   []replacePreview `json:"cards"`
+}
+
+// FindAndReplace replaces text in the front and back of the cards of a deck,
+// optionally limited to cards matching a tag expression. With dry_run the
+// changes are only previewed. Replaced content is kept in the card history.
+func (h *Handler) FindAndReplace(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	idStr := r.PathValue("id")
+	deckID, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Error("Invalid deck ID", err)
+		http.Error(w, "Invalid deck ID", http.StatusBadRequest)
+		return
+	}
+
+	var req replaceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Error("Invalid JSON", err)
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if req.Find == "" {
+		http.Error(w, "find cannot be empty", http.StatusBadRequest)
+		return
+	}
+
+	pattern := req.Find
+	if !req.Regex {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if req.IgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		log.Error("Invalid regex", err)
+		http.Error(w, "Invalid regex: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	replaceFront, replaceBack := len(req.Fields) == 0, len(req.Fields) == 0
+	for _, field := range req.Fields {
+		switch field {
+		case "front":
+			replaceFront = true
+		case "back":
+			replaceBack = true
+		default:
+			http.Error(w, "fields must contain only front, back", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var filter tagexpr.Expr
+	if req.Tags != "" {
+		filter, err = tagexpr.Parse(req.Tags)
+		if err != nil {
+			log.Error("Invalid tags filter", err)
+			http.Error(w, "invalid tags filter: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	deck, err := h.db.GetDeck(userID, deckID)
+	if err != nil {
+		log.Error("Failed to get deck", err)
+		http.Error(w, "Deck not found", http.StatusNotFound)
+		return
+	}
+	if !deck.CanEdit() {
+		http.Error(w, "Cannot edit cards of a deck shared with you as a viewer", http.StatusForbidden)
+		return
+	}
+
+	cards, err := h.db.GetCardsByDeck(userID, deckID, filter)
+	if err != nil {
+		log.Error("Failed to get cards", err)
+		http.Error(w, "Failed to get cards", http.StatusInternalServerError)
+		return
+	}
+
+	replace := func(text string) string {
+		if req.Regex {
+			return re.ReplaceAllString(text, req.Replace)
+		}
+		return re.ReplaceAllLiteralString(text, req.Replace)
+	}
+
+	resp := replaceResponse{DryRun: req.DryRun, Cards: []replacePreview{}}
+	var changed []*models.Card
+
+	for i := range cards {
+		card := &cards[i]
+		front, back := card.Front, card.Back
+		if replaceFront {
+			front = replace(front)
+		}
+		if replaceBack {
+			back = replace(back)
+		}
+		if front == card.Front && back == card.Back {
+			continue
+		}
+
+		preview := replacePreview{
+			CardID:    card.ID,
+			Front:     front,
+			Back:      back,
+			FrontDiff: diff.Words(card.Front, front),
+			BackDiff:  diff.Words(card.Back, back),
+		}
+
+		card.Front, card.Back = front, back
+		card.UpdatedBy = requestAuthor(r)
+		if err := card.Validate(); err != nil {
+			preview.Error = err.Error()
+		} else if err := card.Render(); err != nil {
+			preview.Error = err.Error()
+		} else {
+			changed = append(changed, card)
+		}
+		if preview.Error != "" {
+			resp.Invalid++
+		}
+
+		resp.Cards = append(resp.Cards, preview)
+	}
+	resp.Matched = len(changed)
+
+	if !req.DryRun && len(changed) > 0 {
+		if err := h.db.UpdateCards(userID, changed); err != nil {
+			log.Error("Failed to update cards", err)
+			http.Error(w, "Failed to update cards", http.StatusInternalServerError)
+			return
+		}
+		resp.Updated = len(changed)
+
+		log.Info("Cards updated by find and replace", "deck_id", deckID, "count", resp.Updated)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
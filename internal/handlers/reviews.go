@@ -2,14 +2,18 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/dmltdev/flashcards/internal/database"
 	"github.com/dmltdev/flashcards/internal/models"
 )
 
 func (h *Handler) CreateReview(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
 	idStr := r.PathValue("id")
 	cardID, err := strconv.Atoi(idStr)
 	if err != nil {
@@ -26,6 +30,7 @@ func (h *Handler) CreateReview(w http.ResponseWriter, r *http.Request) {
 	}
 
 	review.CardID = cardID
+	review.UserID = userID
 	review.ReviewedAt = time.Now()
 
 	if err := review.Validate(); err != nil {
@@ -34,14 +39,28 @@ func (h *Handler) CreateReview(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if review.Quality >= 4 {
-		review.NextReviewAt = time.Now().Add(3 * 24 * time.Hour)
-	} else {
-		review.NextReviewAt = time.Now().Add(24 * time.Hour)
+	options, err := h.db.GetCardOptions(userID, cardID)
+	if err != nil {
+		log.Error("Failed to get card options", err)
+		http.Error(w, "Card not found", http.StatusNotFound)
+		return
 	}
 
-	if err := h.db.CreateReview(&review); err != nil {
+	history, err := h.db.GetReviewsByCard(userID, cardID)
+	if err != nil {
+		log.Error("Failed to get reviews", err)
+		http.Error(w, "Failed to create review", http.StatusInternalServerError)
+		return
+	}
+
+	review.Schedule(*options, history)
+
+	if err := h.db.CreateReview(userID, &review); err != nil {
 		log.Error("Failed to create review", err)
+		if errors.Is(err, database.ErrCardNotFound) {
+			http.Error(w, "Card not found", http.StatusNotFound)
+			return
+		}
 		http.Error(w, "Failed to create review", http.StatusInternalServerError)
 		return
 	}
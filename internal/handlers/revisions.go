@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/dmltdev/flashcards/internal/database"
+	"github.com/dmltdev/flashcards/internal/models"
+)
+
+type revertRequest struct {
+	RevisionID int `json:"revision_id"`
+}
+
+// requestAuthor names who makes a change to a card in its revision history.
+func requestAuthor(r *http.Request) string {
+	return requestUser(r).Email
+}
+
+func (h *Handler) GetCardHistory(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	idStr := r.PathValue("id")
+	cardID, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Error("Invalid card ID", err)
+		http.Error(w, "Invalid card ID", http.StatusBadRequest)
+		return
+	}
+
+	history, err := h.db.GetCardHistory(userID, cardID)
+	if err != nil {
+		log.Error("Failed to get card history", err)
+		http.Error(w, "Card not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
+
+func (h *Handler) RevertCard(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	idStr := r.PathValue("id")
+	cardID, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Error("Invalid card ID", err)
+		http.Error(w, "Invalid card ID", http.StatusBadRequest)
+		return
+	}
+
+	var req revertRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Error("Invalid JSON", err)
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	revision, err := h.db.GetCardRevision(userID, cardID, req.RevisionID)
+	if err != nil {
+		log.Error("Failed to get card revision", err)
+		http.Error(w, "Card revision not found", http.StatusNotFound)
+		return
+	}
+
+	card, err := h.db.GetCard(userID, cardID)
+	if err != nil {
+		log.Error("Failed to get card", err)
+		http.Error(w, "Card not found", http.StatusNotFound)
+		return
+	}
+
+	deck, err := h.db.GetDeck(userID, card.DeckID)
+	if err != nil {
+		log.Error("Failed to get deck", err)
+		http.Error(w, "Card not found", http.StatusNotFound)
+		return
+	}
+	if !deck.CanEdit() {
+		http.Error(w, "Cannot revert cards of a deck shared with you as a viewer", http.StatusForbidden)
+		return
+	}
+
+	card.Front = revision.Front
+	card.Back = revision.Back
+	card.Format = revision.Format
+	card.UpdatedBy = requestAuthor(r)
+
+	if err := h.saveCard(userID, card); err != nil {
+		log.Error("Failed to revert card", err)
+		if errors.Is(err, database.ErrCardNotFound) {
+			http.Error(w, "Card not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to revert card", http.StatusInternalServerError)
+		return
+	}
+
+	log.Info("Card reverted", "card_id", cardID, "revision_id", revision.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(card)
+}
+
+// saveCard renders and stores the content of an existing card. The content
+// it replaces is kept as a revision.
+func (h *Handler) saveCard(userID int, card *models.Card) error {
+	if err := card.Render(); err != nil {
+		return err
+	}
+	return h.db.UpdateCard(userID, card)
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+func (h *Handler) SearchCards(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	params := r.URL.Query()
+
+	q := strings.TrimSpace(params.Get("q"))
+	if q == "" {
+		http.Error(w, "q cannot be empty", http.StatusBadRequest)
+		return
+	}
+
+	var deckIDs []int
+	for _, idStr := range params["deck_id"] {
+		deckID, err := strconv.Atoi(idStr)
+		if err != nil {
+			log.Error("Invalid deck ID", err)
+			http.Error(w, "Invalid deck ID", http.StatusBadRequest)
+			return
+		}
+		deckIDs = append(deckIDs, deckID)
+	}
+
+	limit, offset, err := parsePagination(r, defaultSearchLimit, maxSearchLimit)
+	if err != nil {
+		log.Error("Invalid pagination", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results, err := h.db.SearchCards(userID, q, deckIDs, limit, offset)
+	if err != nil {
+		log.Error("Failed to search cards", err)
+		http.Error(w, "Failed to search cards", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dmltdev/flashcards/internal/database"
+	"github.com/dmltdev/flashcards/internal/models"
+)
+
+func (h *Handler) GetDeckShares(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	idStr := r.PathValue("id")
+	deckID, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Error("Invalid deck ID", err)
+		http.Error(w, "Invalid deck ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.db.GetDeck(userID, deckID); err != nil {
+		log.Error("Failed to get deck", err)
+		http.Error(w, "Deck not found", http.StatusNotFound)
+		return
+	}
+
+	shares, err := h.db.GetDeckShares(userID, deckID)
+	if err != nil {
+		log.Error("Failed to get deck shares", err)
+		http.Error(w, "Failed to get deck shares", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(shares)
+}
+
+// ShareDeck shares a deck with the user having the given email, or changes
+// the role of the user if the deck is already shared with them.
+func (h *Handler) ShareDeck(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	idStr := r.PathValue("id")
+	deckID, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Error("Invalid deck ID", err)
+		http.Error(w, "Invalid deck ID", http.StatusBadRequest)
+		return
+	}
+
+	var share models.DeckShare
+	if err := json.NewDecoder(r.Body).Decode(&share); err != nil {
+		log.Error("Invalid JSON", err)
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	share.DeckID = deckID
+	share.Email = strings.ToLower(strings.TrimSpace(share.Email))
+
+	if err := share.Validate(); err != nil {
+		log.Error("Invalid deck share", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	deck, err := h.db.GetDeck(userID, deckID)
+	if err != nil {
+		log.Error("Failed to get deck", err)
+		http.Error(w, "Deck not found", http.StatusNotFound)
+		return
+	}
+	if deck.Role != models.DeckRoleOwner {
+		http.Error(w, "Only owners can share the deck", http.StatusForbidden)
+		return
+	}
+	// Filtered decks borrow cards from the decks of their owner
+	if deck.Kind == models.DeckKindFiltered {
+		http.Error(w, "Cannot share a filtered deck", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.ShareDeck(userID, &share); err != nil {
+		log.Error("Failed to share deck", err)
+		switch {
+		case errors.Is(err, database.ErrUserNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, database.ErrShareWithOwner):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, "Failed to share deck", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	log.Info("Deck shared", "deck_id", deckID, "user_id", share.UserID, "role", share.Role)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(share)
+}
+
+// UnshareDeck removes a user from a deck. Owners can remove anyone and every
+// user can remove themselves.
+func (h *Handler) UnshareDeck(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	idStr := r.PathValue("id")
+	deckID, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Error("Invalid deck ID", err)
+		http.Error(w, "Invalid deck ID", http.StatusBadRequest)
+		return
+	}
+
+	targetStr := r.PathValue("user_id")
+	targetID, err := strconv.Atoi(targetStr)
+	if err != nil {
+		log.Error("Invalid user ID", err)
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.UnshareDeck(userID, deckID, targetID); err != nil {
+		log.Error("Failed to unshare deck", err)
+		http.Error(w, "Deck share not found", http.StatusNotFound)
+		return
+	}
+
+	log.Info("Deck unshared", "deck_id", deckID, "user_id", targetID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dmltdev/flashcards/internal/content"
+	"github.com/dmltdev/flashcards/internal/models"
+)
+
+const (
+	defaultSimilarLimit = 10
+	maxSimilarLimit     = 50
+	nearDuplicateLimit  = 5
+)
+
+type cardWarning struct {
+	Code    string              `json:"code"`
+	Message string              `json:"message"`
+	Card    *models.SimilarCard `json:"card"`
+}
+
+type createCardResponse struct {
+	*models.Card
+	Warnings []cardWarning `json:"warnings,omitempty"`
+}
+
+// GetSimilarCards returns the cards most similar to the text query parameter.
+func (h *Handler) GetSimilarCards(w http.ResponseWriter, r *http.Request) {
+	text := strings.TrimSpace(r.URL.Query().Get("text"))
+	if text == "" {
+		http.Error(w, "text cannot be empty", http.StatusBadRequest)
+		return
+	}
+
+	normalized, err := content.Normalize(content.FormatPlain, text)
+	if err != nil {
+		log.Error("Failed to normalize text", err)
+		http.Error(w, "Invalid text", http.StatusBadRequest)
+		return
+	}
+
+	h.writeSimilarCards(w, r, normalized, 0)
+}
+
+// GetSimilarToCard returns the cards most similar to an existing card.
+func (h *Handler) GetSimilarToCard(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	idStr := r.PathValue("id")
+	cardID, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Error("Invalid card ID", err)
+		http.Error(w, "Invalid card ID", http.StatusBadRequest)
+		return
+	}
+
+	card, err := h.db.GetCard(userID, cardID)
+	if err != nil {
+		log.Error("Failed to get card", err)
+		http.Error(w, "Card not found", http.StatusNotFound)
+		return
+	}
+
+	normalized, err := content.Normalize(card.Format, card.Front)
+	if err != nil {
+		log.Error("Failed to normalize card", err)
+		http.Error(w, "Failed to find similar cards", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeSimilarCards(w, r, normalized, cardID)
+}
+
+func (h *Handler) writeSimilarCards(w http.ResponseWriter, r *http.Request, normalized string, excludeID int) {
+	userID := requestUser(r).ID
+
+	limit, _, err := parsePagination(r, defaultSimilarLimit, maxSimilarLimit)
+	if err != nil {
+		log.Error("Invalid limit", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cards, err := h.db.FindSimilarCards(userID, normalized, excludeID, limit)
+	if err != nil {
+		log.Error("Failed to find similar cards", err)
+		http.Error(w, "Failed to find similar cards", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cards)
+}
+
+// nearDuplicateWarnings looks up cards similar to a newly created card.
+// Failures are logged and produce no warnings, since they must not fail the
+// creation of the card.
+func (h *Handler) nearDuplicateWarnings(userID int, card *models.Card) []cardWarning {
+	similar, err := h.db.FindSimilarCards(userID, card.FrontNormalized, card.ID, nearDuplicateLimit)
+	if err != nil {
+		log.Error("Failed to find near-duplicate cards", err)
+		return nil
+	}
+
+	var warnings []cardWarning
+	for i := range similar {
+		warnings = append(warnings, cardWarning{
+			Code:    "near_duplicate",
+			Message: "A card with a similar front already exists",
+			Card:    &similar[i],
+		})
+	}
+	return warnings
+}
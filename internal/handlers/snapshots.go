@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dmltdev/flashcards/internal/models"
+)
+
+type createSnapshotRequest struct {
+	Label string `json:"label"`
+}
+
+func (h *Handler) CreateDeckSnapshot(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	idStr := r.PathValue("id")
+	deckID, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Error("Invalid deck ID", err)
+		http.Error(w, "Invalid deck ID", http.StatusBadRequest)
+		return
+	}
+
+	var req createSnapshotRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		log.Error("Invalid JSON", err)
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	label := strings.TrimSpace(req.Label)
+	if len(label) > 255 {
+		http.Error(w, "label cannot be longer than 255 characters", http.StatusBadRequest)
+		return
+	}
+
+	deck, err := h.db.GetDeck(userID, deckID)
+	if err != nil {
+		log.Error("Failed to get deck", err)
+		http.Error(w, "Deck not found", http.StatusNotFound)
+		return
+	}
+	if !deck.CanEdit() {
+		http.Error(w, "Cannot snapshot a deck shared with you as a viewer", http.StatusForbidden)
+		return
+	}
+
+	snapshot, err := h.db.CreateDeckSnapshot(userID, deckID, label, requestAuthor(r))
+	if err != nil {
+		log.Error("Failed to create deck snapshot", err)
+		http.Error(w, "Failed to create deck snapshot", http.StatusInternalServerError)
+		return
+	}
+	snapshot.Content = nil
+
+	log.Info("Deck snapshot created", "deck_id", deckID, "version", snapshot.Version)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(snapshot)
+}
+
+func (h *Handler) GetDeckSnapshots(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	idStr := r.PathValue("id")
+	deckID, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Error("Invalid deck ID", err)
+		http.Error(w, "Invalid deck ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.db.GetDeck(userID, deckID); err != nil {
+		log.Error("Failed to get deck", err)
+		http.Error(w, "Deck not found", http.StatusNotFound)
+		return
+	}
+
+	snapshots, err := h.db.GetDeckSnapshots(userID, deckID)
+	if err != nil {
+		log.Error("Failed to get deck snapshots", err)
+		http.Error(w, "Failed to get deck snapshots", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(snapshots)
+}
+
+func (h *Handler) GetDeckSnapshot(w http.ResponseWriter, r *http.Request) {
+	_, snapshot, ok := h.loadDeckSnapshot(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(snapshot)
+}
+
+// DiffDeckSnapshot compares a snapshot with the version given by the to
+// query parameter, or with the current content of the deck when it is absent.
+func (h *Handler) DiffDeckSnapshot(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	_, snapshot, ok := h.loadDeckSnapshot(w, r)
+	if !ok {
+		return
+	}
+
+	var to *int
+	target := &models.DeckSnapshot{}
+	if toStr := r.URL.Query().Get("to"); toStr != "" {
+		version, err := strconv.Atoi(toStr)
+		if err != nil {
+			http.Error(w, "to must be a snapshot version", http.StatusBadRequest)
+			return
+		}
+
+		target, err = h.db.GetDeckSnapshot(userID, snapshot.DeckID, version)
+		if err != nil {
+			log.Error("Failed to get deck snapshot", err)
+			http.Error(w, "Deck snapshot not found", http.StatusNotFound)
+			return
+		}
+		to = &version
+	} else {
+		content, err := h.db.GetDeckContent(userID, snapshot.DeckID)
+		if err != nil {
+			log.Error("Failed to get deck content", err)
+			http.Error(w, "Deck not found", http.StatusNotFound)
+			return
+		}
+		target.Content = content
+	}
+
+	diff := models.DiffSnapshots(snapshot.Content, target.Content)
+	diff.From = snapshot.Version
+	diff.To = to
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(diff)
+}
+
+// RestoreDeckSnapshot rolls a deck back to the content of a snapshot. Review
+// history is kept for every card that still exists.
+func (h *Handler) RestoreDeckSnapshot(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	deck, snapshot, ok := h.loadDeckSnapshot(w, r)
+	if !ok {
+		return
+	}
+	if deck.Role != models.DeckRoleOwner {
+		http.Error(w, "Only owners can restore a deck snapshot", http.StatusForbidden)
+		return
+	}
+
+	cards := make([]*models.Card, len(snapshot.Content.Cards))
+	for i, c := range snapshot.Content.Cards {
+		card := &models.Card{
+			ID:        c.ID,
+			DeckID:    snapshot.DeckID,
+			Front:     c.Front,
+			Back:      c.Back,
+			Format:    c.Format,
+			Tags:      c.Tags,
+			UpdatedBy: requestAuthor(r),
+		}
+		if err := card.Render(); err != nil {
+			log.Error("Failed to render card", err)
+			http.Error(w, "Failed to restore deck snapshot", http.StatusInternalServerError)
+			return
+		}
+		cards[i] = card
+	}
+
+	restore, err := h.db.RestoreDeckSnapshot(userID, snapshot, cards)
+	if err != nil {
+		log.Error("Failed to restore deck snapshot", err)
+		http.Error(w, "Failed to restore deck snapshot", http.StatusInternalServerError)
+		return
+	}
+
+	log.Info("Deck snapshot restored", "deck_id", snapshot.DeckID, "restore", restore)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(restore)
+}
+
+// loadDeckSnapshot reads the deck ID and snapshot version from the path and
+// loads the live deck and its snapshot. It writes the error response itself
+// and returns false when the snapshot cannot be loaded.
+func (h *Handler) loadDeckSnapshot(w http.ResponseWriter, r *http.Request) (*models.Deck, *models.DeckSnapshot, bool) {
+	userID := requestUser(r).ID
+
+	deckID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		log.Error("Invalid deck ID", err)
+		http.Error(w, "Invalid deck ID", http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	version, err := strconv.Atoi(r.PathValue("version"))
+	if err != nil {
+		log.Error("Invalid snapshot version", err)
+		http.Error(w, "Invalid snapshot version", http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	deck, err := h.db.GetDeck(userID, deckID)
+	if err != nil {
+		log.Error("Failed to get deck", err)
+		http.Error(w, "Deck not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+
+	snapshot, err := h.db.GetDeckSnapshot(userID, deckID, version)
+	if err != nil {
+		log.Error("Failed to get deck snapshot", err)
+		http.Error(w, "Deck snapshot not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+
+	return deck, snapshot, true
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxStatsWindows = 10
+	maxStatsDays    = 3650
+)
+
+var defaultRetentionWindows = []int{7, 30, 90}
+
+func (h *Handler) GetDeckStats(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Error("Invalid deck ID", err)
+		http.Error(w, "Invalid deck ID", http.StatusBadRequest)
+		return
+	}
+
+	windows, days, err := parseStatsParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.db.GetDeck(userID, id); err != nil {
+		log.Error("Failed to get deck", err)
+		http.Error(w, "Deck not found", http.StatusNotFound)
+		return
+	}
+
+	stats, err := h.db.GetDeckStats(userID, id, windows, days)
+	if err != nil {
+		log.Error("Failed to get deck stats", err)
+		http.Error(w, "Failed to get deck stats", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// parseStatsParams reads the windows query parameter, a comma separated list
+// of retention windows in days, and the days query parameter, the number of
+// days covered by the reviews per day.
+func parseStatsParams(r *http.Request) ([]int, int, error) {
+	params := r.URL.Query()
+
+	windows := defaultRetentionWindows
+	if windowsStr := params.Get("windows"); windowsStr != "" {
+		windows = nil
+		for _, s := range strings.Split(windowsStr, ",") {
+			window, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil || window <= 0 || window > maxStatsDays {
+				return nil, 0, errors.New("windows must be positive numbers of days")
+			}
+			windows = append(windows, window)
+		}
+		slices.Sort(windows)
+		windows = slices.Compact(windows)
+		if len(windows) > maxStatsWindows {
+			return nil, 0, errors.New("too many windows")
+		}
+	}
+
+	days := 30
+	if daysStr := params.Get("days"); daysStr != "" {
+		d, err := strconv.Atoi(daysStr)
+		if err != nil || d <= 0 {
+			return nil, 0, errors.New("days must be a positive integer")
+		}
+		days = min(d, 365)
+	}
+
+	return windows, days, nil
+}
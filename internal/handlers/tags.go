@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/dmltdev/flashcards/internal/models"
+	"github.com/dmltdev/flashcards/internal/tagexpr"
+)
+
+type tagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+type bulkTagRequest struct {
+	CardIDs []int    `json:"card_ids"`
+	Tags    []string `json:"tags"`
+}
+
+func (h *Handler) GetTags(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	tags, err := h.db.GetAllTags(userID)
+	if err != nil {
+		log.Error("Failed to get all tags", err)
+		http.Error(w, "Failed to get all tags", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tags)
+}
+
+func (h *Handler) AddCardTags(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	idStr := r.PathValue("id")
+	cardID, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Error("Invalid card ID", err)
+		http.Error(w, "Invalid card ID", http.StatusBadRequest)
+		return
+	}
+
+	var req tagsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Error("Invalid JSON", err)
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	tags, err := models.NormalizeTags(req.Tags)
+	if err != nil {
+		log.Error("Invalid tags", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.AddCardTags(userID, cardID, tags); err != nil {
+		log.Error("Failed to add card tags", err)
+		http.Error(w, "Card not found", http.StatusNotFound)
+		return
+	}
+
+	card, err := h.db.GetCard(userID, cardID)
+	if err != nil {
+		log.Error("Failed to get card", err)
+		http.Error(w, "Card not found", http.StatusNotFound)
+		return
+	}
+
+	log.Info("Card tags added", "card_id", cardID, "tags", tags)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(card)
+}
+
+func (h *Handler) RemoveCardTag(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	idStr := r.PathValue("id")
+	cardID, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Error("Invalid card ID", err)
+		http.Error(w, "Invalid card ID", http.StatusBadRequest)
+		return
+	}
+
+	tags, err := models.NormalizeTags([]string{r.PathValue("tag")})
+	if err != nil {
+		log.Error("Invalid tag", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.RemoveCardTag(userID, cardID, tags[0]); err != nil {
+		log.Error("Failed to remove card tag", err)
+		http.Error(w, "Card tag not found", http.StatusNotFound)
+		return
+	}
+
+	log.Info("Card tag removed", "card_id", cardID, "tag", tags[0])
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) BulkTagCards(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	var req bulkTagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Error("Invalid JSON", err)
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if len(req.CardIDs) == 0 {
+		http.Error(w, "card_ids cannot be empty", http.StatusBadRequest)
+		return
+	}
+
+	tags, err := models.NormalizeTags(req.Tags)
+	if err != nil {
+		log.Error("Invalid tags", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(tags) == 0 {
+		http.Error(w, "tags cannot be empty", http.StatusBadRequest)
+		return
+	}
+
+	count, err := h.db.BulkTagCards(userID, req.CardIDs, tags)
+	if err != nil {
+		log.Error("Failed to bulk tag cards", err)
+		http.Error(w, "Failed to bulk tag cards", http.StatusInternalServerError)
+		return
+	}
+
+	log.Info("Cards tagged", "card_ids", req.CardIDs, "tags", tags, "count", count)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"tagged": count})
+}
+
+// parseTagFilter reads the optional "tags" query parameter holding a tag
+// expression such as "verbs AND NOT irregular".
+func parseTagFilter(r *http.Request) (tagexpr.Expr, error) {
+	query := r.URL.Query().Get("tags")
+	if query == "" {
+		return nil, nil
+	}
+
+	filter, err := tagexpr.Parse(query)
+	if err != nil {
+		return nil, errors.New("invalid tags filter: " + err.Error())
+	}
+	return filter, nil
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/dmltdev/flashcards/internal/database"
+	"github.com/dmltdev/flashcards/internal/models"
+)
+
+type transferCardsRequest struct {
+	CardIDs []int `json:"card_ids"`
+	DeckID  int   `json:"deck_id"`
+}
+
+func (h *Handler) MoveCards(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	req, ok := h.decodeTransferRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.db.MoveCards(userID, req.CardIDs, req.DeckID); err != nil {
+		log.Error("Failed to move cards", err)
+		if errors.Is(err, database.ErrMoveToOtherOwner) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		http.Error(w, "One or more cards not found", http.StatusNotFound)
+		return
+	}
+
+	log.Info("Cards moved", "card_ids", req.CardIDs, "deck_id", req.DeckID)
+
+	cards, err := h.db.GetCardsByIDs(userID, req.CardIDs)
+	if err != nil {
+		log.Error("Failed to get cards", err)
+		http.Error(w, "Failed to get cards", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cards)
+}
+
+func (h *Handler) CopyCards(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	req, ok := h.decodeTransferRequest(w, r)
+	if !ok {
+		return
+	}
+
+	cards, err := h.db.CopyCards(userID, req.CardIDs, req.DeckID, requestAuthor(r))
+	if err != nil {
+		log.Error("Failed to copy cards", err)
+		http.Error(w, "One or more cards not found", http.StatusNotFound)
+		return
+	}
+
+	log.Info("Cards copied", "card_ids", req.CardIDs, "deck_id", req.DeckID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(cards)
+}
+
+// decodeTransferRequest reads a move or copy request and checks that the
+// target deck exists. It writes the error response itself and returns false
+// when the request cannot be processed.
+func (h *Handler) decodeTransferRequest(w http.ResponseWriter, r *http.Request) (*transferCardsRequest, bool) {
+	userID := requestUser(r).ID
+
+	var req transferCardsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Error("Invalid JSON", err)
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return nil, false
+	}
+
+	if len(req.CardIDs) == 0 {
+		http.Error(w, "card_ids cannot be empty", http.StatusBadRequest)
+		return nil, false
+	}
+	slices.Sort(req.CardIDs)
+	req.CardIDs = slices.Compact(req.CardIDs)
+
+	deck, err := h.db.GetDeck(userID, req.DeckID)
+	if err != nil {
+		log.Error("Failed to get target deck", err)
+		http.Error(w, "Target deck not found", http.StatusNotFound)
+		return nil, false
+	}
+	if deck.Kind == models.DeckKindFiltered {
+		http.Error(w, "Cannot move or copy cards into a filtered deck", http.StatusBadRequest)
+		return nil, false
+	}
+	if !deck.CanEdit() {
+		http.Error(w, "Cannot move or copy cards into a deck shared with you as a viewer", http.StatusForbidden)
+		return nil, false
+	}
+
+	return &req, true
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+func (h *Handler) DeleteDeck(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Error("Invalid deck ID", err)
+		http.Error(w, "Invalid deck ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.DeleteDeck(userID, id); err != nil {
+		log.Error("Failed to delete deck", err)
+		http.Error(w, "Deck not found", http.StatusNotFound)
+		return
+	}
+
+	log.Info("Deck moved to trash", "deck_id", id)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) DeleteCard(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Error("Invalid card ID", err)
+		http.Error(w, "Invalid card ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.DeleteCard(userID, id); err != nil {
+		log.Error("Failed to delete card", err)
+		http.Error(w, "Card not found", http.StatusNotFound)
+		return
+	}
+
+	log.Info("Card moved to trash", "card_id", id)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) GetTrash(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	trash, err := h.db.GetTrash(userID)
+	if err != nil {
+		log.Error("Failed to get trash", err)
+		http.Error(w, "Failed to get trash", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(trash)
+}
+
+func (h *Handler) RestoreDeck(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Error("Invalid deck ID", err)
+		http.Error(w, "Invalid deck ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.RestoreDeck(userID, id); err != nil {
+		log.Error("Failed to restore deck", err)
+		http.Error(w, "Deck not found in trash", http.StatusNotFound)
+		return
+	}
+
+	deck, err := h.db.GetDeck(userID, id)
+	if err != nil {
+		log.Error("Failed to get deck", err)
+		http.Error(w, "Deck not found", http.StatusNotFound)
+		return
+	}
+
+	log.Info("Deck restored", "deck_id", id)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deck)
+}
+
+func (h *Handler) RestoreCard(w http.ResponseWriter, r *http.Request) {
+	userID := requestUser(r).ID
+
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Error("Invalid card ID", err)
+		http.Error(w, "Invalid card ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.RestoreCard(userID, id); err != nil {
+		log.Error("Failed to restore card", err)
+		http.Error(w, "Card not found in trash", http.StatusNotFound)
+		return
+	}
+
+	card, err := h.db.GetCard(userID, id)
+	if err != nil {
+		log.Error("Failed to get card", err)
+		http.Error(w, "Card not found", http.StatusNotFound)
+		return
+	}
+
+	log.Info("Card restored", "card_id", id)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(card)
+}
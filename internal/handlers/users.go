@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/dmltdev/flashcards/internal/auth"
+	"github.com/dmltdev/flashcards/internal/models"
+)
+
+// requestUser returns the authenticated user of a request. The auth
+// middleware sets it for every route that is not public.
+func requestUser(r *http.Request) *models.User {
+	return auth.UserFromContext(r.Context())
+}
+
+func (h *Handler) GetCurrentUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(requestUser(r))
+}
@@ -0,0 +1,31 @@
+package jobs
+
+import (
+	"time"
+
+	"github.com/dmltdev/flashcards/internal/database"
+	"github.com/dmltdev/flashcards/internal/storage"
+)
+
+// CollectMedia deletes the media files no card references anymore and that
+// are older than the grace period, once immediately and then at every
+// interval. It blocks, so run it in its own goroutine.
+func CollectMedia(db *database.DB, store storage.Storage, gracePeriod, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		checksums, err := db.DeleteUnreferencedMedia(gracePeriod, func(checksum string) {
+			if err := store.Delete(checksum); err != nil {
+				log.Error("Failed to delete media file", err, "checksum", checksum)
+			}
+		})
+		if err != nil {
+			log.Error("Failed to collect media", err)
+		} else if len(checksums) > 0 {
+			log.Info("Media collected", "count", len(checksums))
+		}
+
+		<-ticker.C
+	}
+}
@@ -0,0 +1,29 @@
+package jobs
+
+import (
+	"time"
+
+	"github.com/dmltdev/flashcards/internal/database"
+	"github.com/dmltdev/flashcards/internal/logger"
+)
+
+var log = logger.New("jobs")
+
+// PurgeTrash permanently deletes trashed decks and cards older than the
+// retention period, once immediately and then at every interval. It blocks,
+// so run it in its own goroutine.
+func PurgeTrash(db *database.DB, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		decks, cards, err := db.PurgeTrash(retention)
+		if err != nil {
+			log.Error("Failed to purge trash", err)
+		} else if decks > 0 || cards > 0 {
+			log.Info("Trash purged", "decks", decks, "cards", cards)
+		}
+
+		<-ticker.C
+	}
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	ScopeDecksRead       = "decks:read"
+	ScopeDecksWrite      = "decks:write"
+	ScopeCardsRead       = "cards:read"
+	ScopeCardsWrite      = "cards:write"
+	ScopeReviewsRead     = "reviews:read"
+	ScopeReviewsWrite    = "reviews:write"
+	ScopePresetsRead     = "presets:read"
+	ScopePresetsWrite    = "presets:write"
+	ScopeMediaWrite      = "media:write"
+	ScopeClassroomsRead  = "classrooms:read"
+	ScopeClassroomsWrite = "classrooms:write"
+)
+
+var Scopes = []string{
+	ScopeDecksRead,
+	ScopeDecksWrite,
+	ScopeCardsRead,
+	ScopeCardsWrite,
+	ScopeReviewsRead,
+	ScopeReviewsWrite,
+	ScopePresetsRead,
+	ScopePresetsWrite,
+	ScopeMediaWrite,
+	ScopeClassroomsRead,
+	ScopeClassroomsWrite,
+}
+
+// APIKey is a personal key a user's scripts authenticate with instead of the
+// user's password. Only a hash of the key is stored; the key itself is set
+// once, in the response creating it.
+type APIKey struct {
+	ID         int            `json:"id" db:"id"`
+	UserID     int            `json:"user_id" db:"user_id"`
+	Name       string         `json:"name" db:"name"`
+	Prefix     string         `json:"prefix" db:"prefix"`
+	Scopes     pq.StringArray `json:"scopes" db:"scopes"`
+	Key        string         `json:"key,omitempty" db:"-"`
+	ExpiresAt  *time.Time     `json:"expires_at" db:"expires_at"`
+	LastUsedAt *time.Time     `json:"last_used_at" db:"last_used_at"`
+	CreatedAt  time.Time      `json:"created_at" db:"created_at"`
+}
+
+// Normalize trims the name of the key.
+func (k *APIKey) Normalize() {
+	k.Name = strings.TrimSpace(k.Name)
+}
+
+func (k *APIKey) Validate() error {
+	if k.Name == "" {
+		return errors.New("name cannot be empty")
+	}
+	if len(k.Name) > 255 {
+		return errors.New("name cannot be longer than 255 characters")
+	}
+	if len(k.Scopes) == 0 {
+		return errors.New("scopes cannot be empty")
+	}
+	for _, scope := range k.Scopes {
+		if !slices.Contains(Scopes, scope) {
+			return fmt.Errorf("unknown scope %q, must be one of %s", scope, strings.Join(Scopes, ", "))
+		}
+	}
+	if k.ExpiresAt != nil && !k.ExpiresAt.After(time.Now()) {
+		return errors.New("expires_at must be in the future")
+	}
+	return nil
+}
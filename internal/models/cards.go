@@ -4,32 +4,78 @@ import (
 	"errors"
 	"strings"
 	"time"
+	"unicode/utf8"
+
+	"github.com/dmltdev/flashcards/internal/content"
+	"github.com/lib/pq"
 )
 
+// MaxCardFieldLength caps the size of the front and back of a card, which
+// keeps rendering, search and revision diffs cheap.
+const MaxCardFieldLength = 20000
+
+// MaxReviewDurationMs caps the time a single review can report, one hour.
+const MaxReviewDurationMs = 60 * 60 * 1000
+
 type Card struct {
 	ID        int       `json:"id" db:"id"`
     DeckID    int       `json:"deck_id" db:"deck_id"`
     Front     string    `json:"front" db:"front"`
     Back      string    `json:"back" db:"back"`
+    Format    string    `json:"format" db:"format"`
+    FrontHTML string    `json:"front_html" db:"front_html"`
+    BackHTML  string    `json:"back_html" db:"back_html"`
+    FrontNormalized string `json:"-" db:"front_normalized"`
+    UpdatedBy string    `json:"-" db:"updated_by"`
+    Tags      pq.StringArray `json:"tags" db:"tags"`
+    Suspended bool      `json:"suspended" db:"suspended"`
     CreatedAt time.Time `json:"created_at" db:"created_at"`
     UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+    DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+}
+
+type DuplicateGroup struct {
+	NormalizedFront string `json:"normalized_front"`
+	Cards []Card `json:"cards"`
 }
 
 type Deck struct {
     ID int `json:"id" db:"id"`
+	UserID int `json:"user_id" db:"user_id"`
+	Role string `json:"role,omitempty" db:"role"`
+	ParentID *int `json:"parent_id" db:"parent_id"`
 	Name string `json:"name" db:"name"`
+	Kind string `json:"kind" db:"kind"`
+	Filter *DeckFilter `json:"filter,omitempty" db:"filter"`
+	Path string `json:"path,omitempty" db:"path"`
 	Cards []Card `json:"cards,omitempty" db:"-"`
+	Children []Deck `json:"children,omitempty" db:"-"`
 	CardCount int `json:"card_count" db:"card_count"`
+	DueCount int `json:"due_count" db:"due_count"`
+	TotalCardCount int `json:"total_card_count" db:"-"`
+	TotalDueCount int `json:"total_due_count" db:"-"`
+	PresetID *int `json:"preset_id" db:"preset_id"`
+	Options DeckOptions `json:"options" db:"options"`
+	PresetOptions DeckOptions `json:"-" db:"preset_options"`
+	EffectiveOptions DeckOptions `json:"effective_options" db:"-"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+}
+
+type Trash struct {
+	Decks []Deck `json:"decks"`
+	Cards []Card `json:"cards"`
 }
 
 type Review struct {
 	ID int `json:"id" db:"id"`
 	CardID int `json:"card_id" db:"card_id"`
+	UserID int `json:"user_id" db:"user_id"`
 	Quality int `json:"quality" db:"quality"`
 	ReviewedAt time.Time `json:"reviewed_at" db:"reviewed_at"`
 	NextReviewAt time.Time `json:"next_review_at" db:"next_review_at"`
+	DurationMs *int `json:"duration_ms" db:"duration_ms"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
 }
@@ -41,9 +87,43 @@ func (c *Card) Validate() error {
 	if strings.TrimSpace(c.Back) == "" {
 		return errors.New("back cannot be empty")
 	}
+	if utf8.RuneCountInString(c.Front) > MaxCardFieldLength || utf8.RuneCountInString(c.Back) > MaxCardFieldLength {
+		return errors.New("front and back cannot be longer than 20000 characters")
+	}
 	if c.DeckID <= 0 {
 		return errors.New("deck_id must be positive")
 	}
+	if c.Format != "" && !content.IsValidFormat(c.Format) {
+		return errors.New("format must be one of plain, markdown, html")
+	}
+	return nil
+}
+
+// Render fills in the sanitized HTML for the front and back of the card
+// based on its format, along with the normalized front used for duplicate
+// detection. It must be called before the card is persisted.
+func (c *Card) Render() error {
+	if c.Format == "" {
+		c.Format = content.FormatPlain
+	}
+
+	front, err := content.Render(c.Format, c.Front)
+	if err != nil {
+		return err
+	}
+	back, err := content.Render(c.Format, c.Back)
+	if err != nil {
+		return err
+	}
+
+	normalized, err := content.Normalize(c.Format, c.Front)
+	if err != nil {
+		return err
+	}
+
+	c.FrontHTML = front
+	c.BackHTML = back
+	c.FrontNormalized = normalized
 	return nil
 }
 
@@ -51,7 +131,37 @@ func (d *Deck) Validate() error {
 	if strings.TrimSpace(d.Name) == "" {
 		return errors.New("name cannot be empty")
 	}
-	return nil
+	if strings.Contains(d.Name, "::") {
+		return errors.New("name cannot contain \"::\", use parent_id to nest decks")
+	}
+	if d.ParentID != nil && *d.ParentID <= 0 {
+		return errors.New("parent_id must be positive")
+	}
+	if d.PresetID != nil && *d.PresetID <= 0 {
+		return errors.New("preset_id must be positive")
+	}
+	switch d.Kind {
+	case DeckKindNormal:
+		if d.Filter != nil {
+			return errors.New("only filtered decks can have a filter")
+		}
+	case DeckKindFiltered:
+		if d.Filter == nil {
+			return errors.New("filtered decks need a filter")
+		}
+		if err := d.Filter.Validate(); err != nil {
+			return err
+		}
+	default:
+		return errors.New("kind must be one of normal, filtered")
+	}
+	return d.Options.Validate()
+}
+
+// ResolveOptions computes the effective options of the deck: the defaults,
+// overridden by the preset, overridden by the options of the deck itself.
+func (d *Deck) ResolveOptions() {
+	d.EffectiveOptions = DefaultDeckOptions().Merge(d.PresetOptions).Merge(d.Options)
 }
 
 func (r *Review) Validate() error {
@@ -61,5 +171,14 @@ func (r *Review) Validate() error {
 	if r.CardID <= 0 {
 		return errors.New("card_id must be positive")
 	}
+	if r.DurationMs != nil && (*r.DurationMs < 0 || *r.DurationMs > MaxReviewDurationMs) {
+		return errors.New("duration_ms must be between 0 and 3600000")
+	}
 	return nil
-}
\ No newline at end of file
+}
+
+// MediaReferences returns the checksums of the media files referenced from
+// the front and back of the card.
+func (c *Card) MediaReferences() []string {
+	return content.MediaReferences(c.Front + "\n" + c.Back)
+}
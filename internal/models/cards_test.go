@@ -0,0 +1,54 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReviewValidateDuration(t *testing.T) {
+	tests := []struct {
+		name     string
+		duration *int
+		wantErr  bool
+	}{
+		{"no duration", nil, false},
+		{"zero", intPtr(0), false},
+		{"an hour", intPtr(MaxReviewDurationMs), false},
+		{"negative", intPtr(-1), true},
+		{"over an hour", intPtr(MaxReviewDurationMs + 1), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Review{CardID: 1, Quality: 3, DurationMs: tt.duration}
+			if err := r.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func TestCardValidateLength(t *testing.T) {
+	tests := []struct {
+		name    string
+		front   string
+		wantErr bool
+	}{
+		{"at the limit", strings.Repeat("a", MaxCardFieldLength), false},
+		{"multibyte characters at the limit", strings.Repeat("é", MaxCardFieldLength), false},
+		{"over the limit", strings.Repeat("a", MaxCardFieldLength+1), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Card{DeckID: 1, Front: tt.front, Back: "back"}
+			if err := c.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
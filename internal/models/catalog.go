@@ -0,0 +1,81 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// Publication is a deck published to the public catalog. Every publish adds
+// a numbered version of the deck's content, and Version is the latest one.
+// DeckID is nil once the published deck has been purged. Content is only
+// loaded when a single publication is requested.
+type Publication struct {
+	ID          int              `json:"id" db:"id"`
+	DeckID      *int             `json:"deck_id" db:"deck_id"`
+	UserID      int              `json:"user_id" db:"user_id"`
+	Publisher   string           `json:"publisher" db:"publisher"`
+	Name        string           `json:"name" db:"name"`
+	Description string           `json:"description" db:"description"`
+	Version     int              `json:"version" db:"version"`
+	Notes       string           `json:"notes" db:"notes"`
+	CardCount   int              `json:"card_count" db:"card_count"`
+	Subscribers int              `json:"subscribers" db:"subscribers"`
+	Content     *SnapshotContent `json:"content,omitempty" db:"content"`
+	CreatedAt   time.Time        `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at" db:"updated_at"`
+}
+
+// PublishOptions describe a new version of a published deck. Name and
+// Description default to the deck name and the current description.
+type PublishOptions struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Notes       string `json:"notes"`
+}
+
+func (o *PublishOptions) Validate() error {
+	if len(o.Name) > 255 {
+		return errors.New("name cannot be longer than 255 characters")
+	}
+	if len(o.Description) > 2000 {
+		return errors.New("description cannot be longer than 2000 characters")
+	}
+	if len(o.Notes) > 2000 {
+		return errors.New("notes cannot be longer than 2000 characters")
+	}
+	return nil
+}
+
+type Catalog struct {
+	Publications []Publication `json:"publications"`
+	Total        int           `json:"total"`
+	Limit        int           `json:"limit"`
+	Offset       int           `json:"offset"`
+}
+
+// Subscription links a publication to the deck of a subscriber holding
+// their own copy of it. Version is the version the copy was last updated to.
+type Subscription struct {
+	ID              int       `json:"id" db:"id"`
+	PublicationID   int       `json:"publication_id" db:"publication_id"`
+	UserID          int       `json:"user_id" db:"user_id"`
+	DeckID          int       `json:"deck_id" db:"deck_id"`
+	Name            string    `json:"name" db:"name"`
+	Version         int       `json:"version" db:"version"`
+	LatestVersion   int       `json:"latest_version" db:"latest_version"`
+	UpdateAvailable bool      `json:"update_available" db:"-"`
+	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// SubscriptionUpdate reports what pulling a new version of a publication
+// into a subscriber's copy changed. Kept counts the cards changed by the
+// publisher that the subscriber had edited themselves and were left alone.
+type SubscriptionUpdate struct {
+	From    int `json:"from"`
+	To      int `json:"to"`
+	Added   int `json:"added"`
+	Updated int `json:"updated"`
+	Removed int `json:"removed"`
+	Kept    int `json:"kept"`
+}
@@ -0,0 +1,134 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// Roles of the members of a classroom. Teachers manage the classroom, its
+// members and its assignments and follow the progress of the students.
+// Every member can study the assigned decks.
+const (
+	ClassroomRoleTeacher = "teacher"
+	ClassroomRoleStudent = "student"
+)
+
+// Classroom is a class as seen by one of its members, whose role is Role.
+// JoinCode and Members are only shown to teachers.
+type Classroom struct {
+	ID          int               `json:"id" db:"id"`
+	Name        string            `json:"name" db:"name"`
+	Description string            `json:"description" db:"description"`
+	JoinCode    string            `json:"join_code,omitempty" db:"join_code"`
+	Role        string            `json:"role" db:"role"`
+	Students    int               `json:"students" db:"students"`
+	Members     []ClassroomMember `json:"members,omitempty" db:"-"`
+	CreatedAt   time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at" db:"updated_at"`
+}
+
+func (c *Classroom) Validate() error {
+	if strings.TrimSpace(c.Name) == "" {
+		return errors.New("name cannot be empty")
+	}
+	if len(c.Name) > 255 {
+		return errors.New("name cannot be longer than 255 characters")
+	}
+	if len(c.Description) > 2000 {
+		return errors.New("description cannot be longer than 2000 characters")
+	}
+	return nil
+}
+
+type ClassroomMember struct {
+	ClassroomID int       `json:"classroom_id" db:"classroom_id"`
+	UserID      int       `json:"user_id" db:"user_id"`
+	Email       string    `json:"email" db:"email"`
+	Name        string    `json:"name" db:"name"`
+	Role        string    `json:"role" db:"role"`
+	JoinedAt    time.Time `json:"joined_at" db:"joined_at"`
+}
+
+func (m *ClassroomMember) Validate() error {
+	if m.Email == "" {
+		return errors.New("email cannot be empty")
+	}
+	switch m.Role {
+	case ClassroomRoleTeacher, ClassroomRoleStudent:
+	default:
+		return errors.New("role must be one of teacher, student")
+	}
+	return nil
+}
+
+// Assignment asks the students of a classroom to study a deck and its
+// subdecks. TargetMastery is the share of cards, between 0 and 1, students
+// are expected to have mastered by DueAt.
+type Assignment struct {
+	ID            int        `json:"id" db:"id"`
+	ClassroomID   int        `json:"classroom_id" db:"classroom_id"`
+	DeckID        int        `json:"deck_id" db:"deck_id"`
+	DeckName      string     `json:"deck_name" db:"deck_name"`
+	DueAt         *time.Time `json:"due_at" db:"due_at"`
+	TargetMastery *float64   `json:"target_mastery" db:"target_mastery"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+func (a *Assignment) Validate() error {
+	if a.DeckID <= 0 {
+		return errors.New("deck_id must be positive")
+	}
+	if a.TargetMastery != nil && (*a.TargetMastery <= 0 || *a.TargetMastery > 1) {
+		return errors.New("target_mastery must be greater than 0 and at most 1")
+	}
+	return nil
+}
+
+// AssignmentProgress reports how far a student got with an assignment. A
+// card is mastered once it is mature as in the deck stats: its latest review
+// passed with MatureInterval, the longest interval the scheduler gives, which
+// takes an easy answer. Overdue cards are due for review, and once the
+// assignment is past due also include the cards the student never studied.
+// Retention is the share of passed reviews of the assigned cards in the last
+// RetentionDays days. Mastery and Retention are nil when there is nothing
+// to compute them from, and TargetMet is nil without a target.
+type AssignmentProgress struct {
+	AssignmentID   int        `json:"assignment_id" db:"assignment_id"`
+	DeckID         int        `json:"deck_id" db:"deck_id"`
+	UserID         int        `json:"user_id" db:"user_id"`
+	Email          string     `json:"email" db:"email"`
+	Name           string     `json:"name" db:"name"`
+	TotalCards     int        `json:"total_cards" db:"total_cards"`
+	Studied        int        `json:"studied" db:"studied"`
+	Mastered       int        `json:"mastered" db:"mastered"`
+	Overdue        int        `json:"overdue" db:"overdue"`
+	Mastery        *float64   `json:"mastery"`
+	Reviews        int        `json:"reviews" db:"reviews"`
+	Passed         int        `json:"passed" db:"passed"`
+	RetentionDays  int        `json:"retention_days" db:"-"`
+	Retention      *float64   `json:"retention"`
+	LastReviewedAt *time.Time `json:"last_reviewed_at" db:"last_reviewed_at"`
+	DueAt          *time.Time `json:"due_at" db:"due_at"`
+	PastDue        bool       `json:"past_due" db:"past_due"`
+	TargetMastery  *float64   `json:"target_mastery" db:"target_mastery"`
+	TargetMet      *bool      `json:"target_met"`
+}
+
+// Summarize fills in the shares computed from the counts.
+func (p *AssignmentProgress) Summarize(retentionDays int) {
+	p.RetentionDays = retentionDays
+	if p.TotalCards > 0 {
+		mastery := float64(p.Mastered) / float64(p.TotalCards)
+		p.Mastery = &mastery
+	}
+	if p.Reviews > 0 {
+		retention := float64(p.Passed) / float64(p.Reviews)
+		p.Retention = &retention
+	}
+	if p.TargetMastery != nil {
+		met := p.Mastery != nil && *p.Mastery >= *p.TargetMastery
+		p.TargetMet = &met
+	}
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"errors"
+	"strings"
+)
+
+// DeckCloneOptions describes the deck created by cloning another one. An
+// empty name keeps the name of the source deck with a " (copy)" suffix and a
+// nil parent places the clone next to the source deck.
+type DeckCloneOptions struct {
+	Name     string `json:"name"`
+	ParentID *int   `json:"parent_id"`
+	Tags     bool   `json:"tags"`
+	Subdecks bool   `json:"subdecks"`
+}
+
+func (o *DeckCloneOptions) Validate() error {
+	if strings.Contains(o.Name, "::") {
+		return errors.New("name cannot contain \"::\", use parent_id to nest decks")
+	}
+	if o.ParentID != nil && *o.ParentID <= 0 {
+		return errors.New("parent_id must be positive")
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/dmltdev/flashcards/internal/tagexpr"
+)
+
+const (
+	DeckKindNormal   = "normal"
+	DeckKindFiltered = "filtered"
+
+	DueStateAny      = "any"
+	DueStateDue      = "due"
+	DueStateNew      = "new"
+	DueStateDueOrNew = "due_or_new"
+
+	DefaultFilterLimit = 100
+	MaxFilterLimit     = 1000
+)
+
+// DeckFilter is the saved search defining which cards a filtered deck pulls
+// in from their home decks when it is built.
+type DeckFilter struct {
+	Tags            string `json:"tags,omitempty"`
+	Due             string `json:"due,omitempty"`
+	MinLapses       int    `json:"min_lapses,omitempty"`
+	DeckID          *int   `json:"deck_id,omitempty"`
+	AddedWithinDays int    `json:"added_within_days,omitempty"`
+	Limit           int    `json:"limit,omitempty"`
+}
+
+func (f *DeckFilter) Validate() error {
+	if f.Tags != "" {
+		if _, err := tagexpr.Parse(f.Tags); err != nil {
+			return fmt.Errorf("invalid tags filter: %w", err)
+		}
+	}
+	switch f.Due {
+	case "", DueStateAny, DueStateDue, DueStateNew, DueStateDueOrNew:
+	default:
+		return errors.New("due must be one of any, due, new, due_or_new")
+	}
+	if f.MinLapses < 0 {
+		return errors.New("min_lapses cannot be negative")
+	}
+	if f.DeckID != nil && *f.DeckID <= 0 {
+		return errors.New("deck_id must be positive")
+	}
+	if f.AddedWithinDays < 0 {
+		return errors.New("added_within_days cannot be negative")
+	}
+	if f.Limit < 0 || f.Limit > MaxFilterLimit {
+		return fmt.Errorf("limit must be between 1 and %d, or 0 for the default of %d", MaxFilterLimit, DefaultFilterLimit)
+	}
+	return nil
+}
+
+// Value stores the filter as JSONB, see DeckOptions.Value.
+func (f DeckFilter) Value() (driver.Value, error) {
+	b, err := json.Marshal(f)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (f *DeckFilter) Scan(src any) error {
+	*f = DeckFilter{}
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, f)
+	case string:
+		return json.Unmarshal([]byte(v), f)
+	}
+	return fmt.Errorf("cannot scan %T into DeckFilter", src)
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+type Media struct {
+	ID        int       `json:"id" db:"id"`
+	Checksum  string    `json:"checksum" db:"checksum"`
+	MimeType  string    `json:"mime_type" db:"mime_type"`
+	Size      int64     `json:"size" db:"size"`
+	Filename  string    `json:"filename" db:"filename"`
+	URL       string    `json:"url" db:"-"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
@@ -0,0 +1,138 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	SchedulerBasic = "basic"
+
+	// MaxLearningStep keeps learning steps under a day, the interval at
+	// which a card graduates.
+	MaxLearningStep = 24 * 60
+
+	NewCardOrderAdded  = "added"
+	NewCardOrderRandom = "random"
+)
+
+// DeckOptions holds the study settings of a deck. Every field is optional so
+// the same type describes presets, per-deck overrides and, once resolved,
+// the effective settings of a deck. Fields are pointers so an override can
+// set a limit to 0 or clear the learning steps with an empty list.
+//
+// NewCardsPerDay caps the cards studied for the first time each day and
+// ReviewsPerDay the reviews of cards first studied on an earlier day. Cards
+// still going through their learning steps are never capped. LearningSteps
+// are the delays in minutes before a new or forgotten card is shown again.
+type DeckOptions struct {
+	Scheduler      *string `json:"scheduler,omitempty"`
+	NewCardsPerDay *int    `json:"new_cards_per_day,omitempty"`
+	ReviewsPerDay  *int    `json:"reviews_per_day,omitempty"`
+	LearningSteps  *[]int  `json:"learning_steps,omitempty"`
+	NewCardOrder   *string `json:"new_card_order,omitempty"`
+}
+
+type Preset struct {
+	ID        int         `json:"id" db:"id"`
+	UserID    int         `json:"user_id" db:"user_id"`
+	Name      string      `json:"name" db:"name"`
+	Options   DeckOptions `json:"options" db:"options"`
+	DeckCount int         `json:"deck_count" db:"deck_count"`
+	CreatedAt time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at" db:"updated_at"`
+}
+
+func DefaultDeckOptions() DeckOptions {
+	scheduler := SchedulerBasic
+	newCardsPerDay := 20
+	reviewsPerDay := 200
+	learningSteps := []int{1, 10}
+	newCardOrder := NewCardOrderAdded
+
+	return DeckOptions{
+		Scheduler:      &scheduler,
+		NewCardsPerDay: &newCardsPerDay,
+		ReviewsPerDay:  &reviewsPerDay,
+		LearningSteps:  &learningSteps,
+		NewCardOrder:   &newCardOrder,
+	}
+}
+
+// Merge returns the options with every field set in other taking precedence.
+func (o DeckOptions) Merge(other DeckOptions) DeckOptions {
+	if other.Scheduler != nil {
+		o.Scheduler = other.Scheduler
+	}
+	if other.NewCardsPerDay != nil {
+		o.NewCardsPerDay = other.NewCardsPerDay
+	}
+	if other.ReviewsPerDay != nil {
+		o.ReviewsPerDay = other.ReviewsPerDay
+	}
+	if other.LearningSteps != nil {
+		o.LearningSteps = other.LearningSteps
+	}
+	if other.NewCardOrder != nil {
+		o.NewCardOrder = other.NewCardOrder
+	}
+	return o
+}
+
+func (o DeckOptions) Validate() error {
+	if o.Scheduler != nil && *o.Scheduler != SchedulerBasic {
+		return errors.New("scheduler must be basic")
+	}
+	if o.NewCardsPerDay != nil && *o.NewCardsPerDay < 0 {
+		return errors.New("new_cards_per_day cannot be negative")
+	}
+	if o.ReviewsPerDay != nil && *o.ReviewsPerDay < 0 {
+		return errors.New("reviews_per_day cannot be negative")
+	}
+	if o.LearningSteps != nil {
+		for _, step := range *o.LearningSteps {
+			if step <= 0 || step >= MaxLearningStep {
+				return errors.New("learning_steps must be between 1 and 1439 minutes")
+			}
+		}
+	}
+	if o.NewCardOrder != nil && *o.NewCardOrder != NewCardOrderAdded && *o.NewCardOrder != NewCardOrderRandom {
+		return errors.New("new_card_order must be one of added, random")
+	}
+	return nil
+}
+
+// Value stores the options as JSONB. The JSON is passed as a string since
+// lib/pq would send a byte slice as bytea.
+func (o DeckOptions) Value() (driver.Value, error) {
+	b, err := json.Marshal(o)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan reads the options from JSONB. NULL leaves all options unset.
+func (o *DeckOptions) Scan(src any) error {
+	*o = DeckOptions{}
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(v, o)
+	case string:
+		return json.Unmarshal([]byte(v), o)
+	}
+	return fmt.Errorf("cannot scan %T into DeckOptions", src)
+}
+
+func (p *Preset) Validate() error {
+	if strings.TrimSpace(p.Name) == "" {
+		return errors.New("name cannot be empty")
+	}
+	return p.Options.Validate()
+}
@@ -0,0 +1,152 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/dmltdev/flashcards/internal/content"
+	"github.com/lib/pq"
+)
+
+// Modes of a quiz. Typed questions are answered by typing the back of the
+// card, multiple choice questions by picking it among the backs of other
+// cards of the deck.
+const (
+	QuizModeTyped          = "typed"
+	QuizModeMultipleChoice = "multiple_choice"
+)
+
+// A quiz is in progress until it is submitted or its time limit runs out.
+const (
+	QuizStatusInProgress = "in_progress"
+	QuizStatusCompleted  = "completed"
+	QuizStatusTimedOut   = "timed_out"
+)
+
+const (
+	MaxQuizQuestions       = 100
+	MaxQuizTimeLimit       = 24 * 60 * 60
+	QuizChoicesPerQuestion = 4
+)
+
+// QuizOptions describe the quiz to generate from a deck. TimeLimitSeconds is
+// optional.
+type QuizOptions struct {
+	QuestionCount    int    `json:"question_count"`
+	Mode             string `json:"mode"`
+	TimeLimitSeconds *int   `json:"time_limit_seconds"`
+}
+
+func (o *QuizOptions) Validate() error {
+	if o.QuestionCount <= 0 || o.QuestionCount > MaxQuizQuestions {
+		return errors.New("question_count must be between 1 and 100")
+	}
+	switch o.Mode {
+	case QuizModeTyped, QuizModeMultipleChoice:
+	default:
+		return errors.New("mode must be one of typed, multiple_choice")
+	}
+	if o.TimeLimitSeconds != nil && (*o.TimeLimitSeconds <= 0 || *o.TimeLimitSeconds > MaxQuizTimeLimit) {
+		return errors.New("time_limit_seconds must be between 1 and 86400")
+	}
+	return nil
+}
+
+// Quiz is an assessment on a random sample of the cards of a deck. Quizzes
+// do not affect the scheduling of the cards. Correct and Score, the share of
+// questions answered correctly, are set once the quiz is over.
+type Quiz struct {
+	ID               int            `json:"id" db:"id"`
+	UserID           int            `json:"user_id" db:"user_id"`
+	DeckID           int            `json:"deck_id" db:"deck_id"`
+	Mode             string         `json:"mode" db:"mode"`
+	Status           string         `json:"status" db:"status"`
+	QuestionCount    int            `json:"question_count" db:"question_count"`
+	Answered         int            `json:"answered" db:"answered"`
+	Correct          *int           `json:"correct" db:"correct"`
+	Score            *float64       `json:"score" db:"score"`
+	TimeLimitSeconds *int           `json:"time_limit_seconds" db:"time_limit_seconds"`
+	ExpiresAt        *time.Time     `json:"expires_at" db:"expires_at"`
+	SubmittedAt      *time.Time     `json:"submitted_at" db:"submitted_at"`
+	Questions        []QuizQuestion `json:"questions,omitempty" db:"-"`
+	CreatedAt        time.Time      `json:"created_at" db:"created_at"`
+}
+
+// QuizQuestion asks for the back of a card given its front. The expected
+// answer and the grading are only shown once the quiz is over. CardID is
+// nil once the card has been purged.
+type QuizQuestion struct {
+	QuizID        int            `json:"-" db:"quiz_id"`
+	Position      int            `json:"position" db:"position"`
+	CardID        *int           `json:"card_id" db:"card_id"`
+	FrontHTML     string         `json:"front_html" db:"front_html"`
+	Choices       pq.StringArray `json:"choices,omitempty" db:"choices"`
+	Answer        string         `json:"answer,omitempty" db:"answer"`
+	AnswerFormat  string         `json:"-" db:"answer_format"`
+	AnswerHTML    string         `json:"answer_html,omitempty" db:"answer_html"`
+	CorrectChoice *int           `json:"correct_choice,omitempty" db:"correct_choice"`
+	Response      *string        `json:"response" db:"response"`
+	Choice        *int           `json:"choice" db:"choice"`
+	Correct       *bool          `json:"correct,omitempty" db:"correct"`
+	AnsweredAt    *time.Time     `json:"answered_at" db:"answered_at"`
+}
+
+// QuizAnswer answers a question with the typed Response or, in a multiple
+// choice quiz, the index of the chosen choice.
+type QuizAnswer struct {
+	Response *string `json:"response"`
+	Choice   *int    `json:"choice"`
+}
+
+// Grade records the answer to the question and whether it is correct. Typed
+// answers are compared with the back of the card ignoring case, whitespace,
+// markup and trailing punctuation.
+func (q *QuizQuestion) Grade(answer QuizAnswer) error {
+	var correct bool
+	if q.CorrectChoice != nil {
+		if answer.Choice == nil || *answer.Choice < 0 || *answer.Choice >= len(q.Choices) {
+			return errors.New("choice must be the index of one of the choices")
+		}
+		correct = *answer.Choice == *q.CorrectChoice
+		q.Choice = answer.Choice
+	} else {
+		if answer.Response == nil {
+			return errors.New("response cannot be empty")
+		}
+		response, err := normalizeAnswer(content.FormatPlain, *answer.Response)
+		if err != nil {
+			return err
+		}
+		expected, err := normalizeAnswer(q.AnswerFormat, q.Answer)
+		if err != nil {
+			return err
+		}
+		correct = response != "" && response == expected
+		q.Response = answer.Response
+	}
+	q.Correct = &correct
+	return nil
+}
+
+func normalizeAnswer(format, text string) (string, error) {
+	normalized, err := content.Normalize(format, text)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(normalized, " .!?;,"), nil
+}
+
+// Conceal hides the answers and the grading while the quiz is in progress.
+func (q *Quiz) Conceal() {
+	if q.Status != QuizStatusInProgress {
+		return
+	}
+	for i := range q.Questions {
+		question := &q.Questions[i]
+		question.Answer = ""
+		question.AnswerHTML = ""
+		question.CorrectChoice = nil
+		question.Correct = nil
+	}
+}
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/dmltdev/flashcards/internal/content"
+)
+
+func TestGradeTyped(t *testing.T) {
+	tests := []struct {
+		name     string
+		answer   string
+		format   string
+		response string
+		want     bool
+	}{
+		{"exact", "Paris", content.FormatPlain, "Paris", true},
+		{"ignores case", "Paris", content.FormatPlain, "pARIS", true},
+		{"ignores whitespace", "New York", content.FormatPlain, "  new \t york ", true},
+		{"ignores trailing punctuation", "Paris", content.FormatPlain, "Paris.", true},
+		{"ignores punctuation after a space", "Paris", content.FormatPlain, "Paris !", true},
+		{"ignores punctuation of the answer", "Paris!", content.FormatPlain, "paris", true},
+		{"ignores markdown", "**Paris**", content.FormatMarkdown, "paris", true},
+		{"ignores html", "<b>Paris</b>", content.FormatHTML, "Paris", true},
+		{"unescapes entities", "fish &amp; chips", content.FormatHTML, "Fish & Chips", true},
+		{"keeps inner punctuation", "U.S.A", content.FormatPlain, "USA", false},
+		{"wrong answer", "Paris", content.FormatPlain, "London", false},
+		{"partial answer", "New York", content.FormatPlain, "York", false},
+		{"empty response", "Paris", content.FormatPlain, "", false},
+		{"punctuation only", ".", content.FormatPlain, "!", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := QuizQuestion{Answer: tt.answer, AnswerFormat: tt.format}
+			if err := q.Grade(QuizAnswer{Response: &tt.response}); err != nil {
+				t.Fatalf("Grade() error = %v", err)
+			}
+			if q.Correct == nil || *q.Correct != tt.want {
+				t.Errorf("Grade(%q) against %q correct = %v, want %v", tt.response, tt.answer, q.Correct, tt.want)
+			}
+			if q.Response == nil || *q.Response != tt.response {
+				t.Errorf("Grade() response = %v, want %q", q.Response, tt.response)
+			}
+		})
+	}
+}
+
+func TestGradeMultipleChoice(t *testing.T) {
+	choice := func(i int) *int { return &i }
+
+	tests := []struct {
+		name    string
+		choice  *int
+		want    bool
+		wantErr bool
+	}{
+		{"correct choice", choice(2), true, false},
+		{"wrong choice", choice(0), false, false},
+		{"last choice", choice(3), false, false},
+		{"no choice", nil, false, true},
+		{"negative choice", choice(-1), false, true},
+		{"choice out of range", choice(4), false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := QuizQuestion{Choices: []string{"a", "b", "c", "d"}, CorrectChoice: choice(2)}
+			err := q.Grade(QuizAnswer{Choice: tt.choice})
+			if tt.wantErr {
+				if err == nil || q.Correct != nil {
+					t.Errorf("Grade() error = %v, correct = %v, want an error and no grade", err, q.Correct)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Grade() error = %v", err)
+			}
+			if *q.Correct != tt.want || q.Choice != tt.choice {
+				t.Errorf("Grade() correct = %v, choice = %v, want %v, %v", *q.Correct, q.Choice, tt.want, tt.choice)
+			}
+		})
+	}
+}
+
+func TestGradeTypedRequiresResponse(t *testing.T) {
+	q := QuizQuestion{Answer: "Paris", AnswerFormat: content.FormatPlain}
+	if err := q.Grade(QuizAnswer{}); err == nil || q.Correct != nil {
+		t.Errorf("Grade() error = %v, correct = %v, want an error and no grade", err, q.Correct)
+	}
+}
+
+func TestConceal(t *testing.T) {
+	correct := true
+	correctChoice := 1
+	quiz := func(status string) *Quiz {
+		return &Quiz{Status: status, Questions: []QuizQuestion{{
+			FrontHTML:     "<p>Capital of France?</p>",
+			Answer:        "Paris",
+			AnswerHTML:    "<p>Paris</p>",
+			CorrectChoice: &correctChoice,
+			Correct:       &correct,
+		}}}
+	}
+
+	inProgress := quiz(QuizStatusInProgress)
+	inProgress.Conceal()
+	q := inProgress.Questions[0]
+	if q.Answer != "" || q.AnswerHTML != "" || q.CorrectChoice != nil || q.Correct != nil {
+		t.Errorf("Conceal() left answers of a quiz in progress: %+v", q)
+	}
+	if q.FrontHTML == "" {
+		t.Error("Conceal() hid the question")
+	}
+
+	for _, status := range []string{QuizStatusCompleted, QuizStatusTimedOut} {
+		over := quiz(status)
+		over.Conceal()
+		if q := over.Questions[0]; q.Answer == "" || q.CorrectChoice == nil || q.Correct == nil {
+			t.Errorf("Conceal() hid the answers of a %s quiz: %+v", status, q)
+		}
+	}
+}
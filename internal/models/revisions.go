@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"github.com/dmltdev/flashcards/internal/diff"
+)
+
+// CardRevision is a version of a card's content. The current version of the
+// card is returned as a revision with ID 0 and Current set.
+type CardRevision struct {
+	ID        int           `json:"id" db:"id"`
+	CardID    int           `json:"card_id" db:"card_id"`
+	Front     string        `json:"front" db:"front"`
+	Back      string        `json:"back" db:"back"`
+	Format    string        `json:"format" db:"format"`
+	Author    string        `json:"author" db:"author"`
+	Current   bool          `json:"current" db:"current"`
+	FrontDiff []diff.Change `json:"front_diff,omitempty" db:"-"`
+	BackDiff  []diff.Change `json:"back_diff,omitempty" db:"-"`
+	CreatedAt time.Time     `json:"created_at" db:"created_at"`
+}
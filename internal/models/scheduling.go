@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// Intervals of the basic scheduler once a card has gone through its
+// learning steps: a day when it was recalled, three when it was easy.
+const (
+	GoodInterval = 24 * time.Hour
+	EasyInterval = 3 * 24 * time.Hour
+)
+
+// LearningStep returns how many learning steps a card has passed given the
+// earlier reviews of the user, newest first, and false once the card has
+// graduated. New cards and cards that were just forgotten start at step 0.
+func LearningStep(history []Review) (int, bool) {
+	step := 0
+	for _, review := range history {
+		if review.Quality < 3 {
+			return step, true
+		}
+		if review.NextReviewAt.Sub(review.ReviewedAt) >= GoodInterval {
+			return step, false
+		}
+		step++
+	}
+	return step, true
+}
+
+// Schedule sets when the card is due again after the review. Forgotten cards
+// go back to the first learning step and recalled cards move on to the next
+// one, graduating after the last. Easy cards and cards that already
+// graduated get the regular intervals. history holds the earlier reviews of
+// the card by the user, newest first.
+func (r *Review) Schedule(options DeckOptions, history []Review) {
+	var steps []int
+	if options.LearningSteps != nil {
+		steps = *options.LearningSteps
+	}
+
+	switch {
+	case r.Quality < 3 && len(steps) > 0:
+		r.NextReviewAt = r.ReviewedAt.Add(time.Duration(steps[0]) * time.Minute)
+		return
+	case r.Quality < 3:
+		r.NextReviewAt = r.ReviewedAt.Add(GoodInterval)
+		return
+	case r.Quality >= 4:
+		r.NextReviewAt = r.ReviewedAt.Add(EasyInterval)
+		return
+	}
+
+	if step, learning := LearningStep(history); learning && step+1 < len(steps) {
+		r.NextReviewAt = r.ReviewedAt.Add(time.Duration(steps[step+1]) * time.Minute)
+		return
+	}
+	r.NextReviewAt = r.ReviewedAt.Add(GoodInterval)
+}
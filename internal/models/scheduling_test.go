@@ -0,0 +1,94 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// review returns a review at the given time due again after interval.
+func review(quality int, at time.Time, interval time.Duration) Review {
+	return Review{Quality: quality, ReviewedAt: at, NextReviewAt: at.Add(interval)}
+}
+
+func TestSchedule(t *testing.T) {
+	now := time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+	earlier := now.Add(-time.Hour)
+	steps := func(minutes ...int) DeckOptions {
+		return DeckOptions{LearningSteps: &minutes}
+	}
+
+	tests := []struct {
+		name    string
+		options DeckOptions
+		quality int
+		history []Review
+		want    time.Duration
+	}{
+		{"new card recalled moves to the second step", steps(1, 10), 3, nil, 10 * time.Minute},
+		{"new card forgotten starts at the first step", steps(1, 10), 1, nil, time.Minute},
+		{"new card easy graduates", steps(1, 10), 4, nil, EasyInterval},
+		{"last step recalled graduates", steps(1, 10), 3, []Review{review(3, earlier, 10*time.Minute)}, GoodInterval},
+		{"single step graduates right away", steps(5), 3, nil, GoodInterval},
+		{"graduated card recalled", steps(1, 10), 3, []Review{review(3, earlier, GoodInterval)}, GoodInterval},
+		{"graduated card forgotten relearns", steps(1, 10), 2, []Review{review(3, earlier, GoodInterval)}, time.Minute},
+		{"relearning card recalled moves on", steps(1, 10), 3, []Review{review(1, earlier, time.Minute), review(3, earlier, GoodInterval)}, 10 * time.Minute},
+		{"three steps", steps(1, 10, 60), 3, []Review{review(3, earlier, 10*time.Minute), review(1, earlier, time.Minute)}, time.Hour},
+		{"no steps recalled", steps(), 3, nil, GoodInterval},
+		{"no steps forgotten", steps(), 1, nil, GoodInterval},
+		{"default steps", DefaultDeckOptions(), 3, nil, 10 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Review{Quality: tt.quality, ReviewedAt: now}
+			r.Schedule(tt.options, tt.history)
+			if got := r.NextReviewAt.Sub(now); got != tt.want {
+				t.Errorf("Schedule() interval = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeckOptionsMerge(t *testing.T) {
+	zero := 0
+	empty := []int{}
+	random := NewCardOrderRandom
+
+	got := DefaultDeckOptions().Merge(DeckOptions{
+		NewCardsPerDay: &zero,
+		LearningSteps:  &empty,
+		NewCardOrder:   &random,
+	})
+
+	if *got.NewCardsPerDay != 0 {
+		t.Errorf("NewCardsPerDay = %d, want 0", *got.NewCardsPerDay)
+	}
+	if len(*got.LearningSteps) != 0 {
+		t.Errorf("LearningSteps = %v, want none", *got.LearningSteps)
+	}
+	if *got.NewCardOrder != NewCardOrderRandom {
+		t.Errorf("NewCardOrder = %q, want %q", *got.NewCardOrder, NewCardOrderRandom)
+	}
+	if *got.ReviewsPerDay != *DefaultDeckOptions().ReviewsPerDay {
+		t.Errorf("ReviewsPerDay = %d, want the default", *got.ReviewsPerDay)
+	}
+}
+
+func TestDeckOptionsOverridesSurviveStorage(t *testing.T) {
+	zero := 0
+	empty := []int{}
+	options := DeckOptions{NewCardsPerDay: &zero, LearningSteps: &empty}
+
+	value, err := options.Value()
+	if err != nil {
+		t.Fatalf("Value() error = %v", err)
+	}
+	var stored DeckOptions
+	if err := stored.Scan(value); err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if !reflect.DeepEqual(stored, options) {
+		t.Errorf("stored options = %s, want %s", value, `{"new_cards_per_day":0,"learning_steps":[]}`)
+	}
+}
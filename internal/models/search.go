@@ -0,0 +1,23 @@
+package models
+
+// SearchResult is a card matching a full-text search. Snippets are the text
+// of the card without markup, HTML escaped with the matching terms wrapped
+// in <mark> elements.
+type SearchResult struct {
+	Card
+	Rank         float64 `json:"rank" db:"rank"`
+	FrontSnippet string  `json:"front_snippet" db:"front_snippet"`
+	BackSnippet  string  `json:"back_snippet" db:"back_snippet"`
+}
+
+type SearchResults struct {
+	Results []SearchResult `json:"results"`
+	Total   int            `json:"total"`
+	Limit   int            `json:"limit"`
+	Offset  int            `json:"offset"`
+}
+
+type SimilarCard struct {
+	Card
+	Similarity float64 `json:"similarity" db:"similarity"`
+}
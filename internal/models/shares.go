@@ -0,0 +1,45 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// Roles a user can hold on a deck, from least to most privileged. Viewers
+// can study the cards, editors can also add and edit cards, and owners can
+// also change, delete and share the deck. A role on a deck extends to its
+// subdecks.
+const (
+	DeckRoleViewer = "viewer"
+	DeckRoleEditor = "editor"
+	DeckRoleOwner  = "owner"
+)
+
+// CanEdit reports whether the user the deck was loaded for can add and edit
+// its cards.
+func (d *Deck) CanEdit() bool {
+	return d.Role == DeckRoleEditor || d.Role == DeckRoleOwner
+}
+
+// DeckShare grants a user other than its owner a role on a deck.
+type DeckShare struct {
+	DeckID    int       `json:"deck_id" db:"deck_id"`
+	UserID    int       `json:"user_id" db:"user_id"`
+	Email     string    `json:"email" db:"email"`
+	Name      string    `json:"name" db:"name"`
+	Role      string    `json:"role" db:"role"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+func (s *DeckShare) Validate() error {
+	if s.Email == "" {
+		return errors.New("email cannot be empty")
+	}
+	switch s.Role {
+	case DeckRoleViewer, DeckRoleEditor, DeckRoleOwner:
+	default:
+		return errors.New("role must be one of viewer, editor, owner")
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/dmltdev/flashcards/internal/diff"
+)
+
+type SnapshotCard struct {
+	ID     int      `json:"id"`
+	Front  string   `json:"front"`
+	Back   string   `json:"back"`
+	Format string   `json:"format"`
+	Tags   []string `json:"tags"`
+}
+
+// SnapshotContent is the archived content of a deck: its settings and the
+// cards whose home is the deck, ordered by ID.
+type SnapshotContent struct {
+	Name     string         `json:"name"`
+	PresetID *int           `json:"preset_id"`
+	Options  DeckOptions    `json:"options"`
+	Cards    []SnapshotCard `json:"cards"`
+}
+
+// DeckSnapshot is a numbered version of a deck's content. Content is only
+// loaded when a single snapshot is requested.
+type DeckSnapshot struct {
+	ID        int              `json:"id" db:"id"`
+	DeckID    int              `json:"deck_id" db:"deck_id"`
+	Version   int              `json:"version" db:"version"`
+	Label     string           `json:"label" db:"label"`
+	CardCount int              `json:"card_count" db:"card_count"`
+	Author    string           `json:"author" db:"author"`
+	Content   *SnapshotContent `json:"content,omitempty" db:"content"`
+	CreatedAt time.Time        `json:"created_at" db:"created_at"`
+}
+
+type SnapshotCardChange struct {
+	CardID      int           `json:"card_id"`
+	FrontDiff   []diff.Change `json:"front_diff,omitempty"`
+	BackDiff    []diff.Change `json:"back_diff,omitempty"`
+	FormatFrom  string        `json:"format_from,omitempty"`
+	FormatTo    string        `json:"format_to,omitempty"`
+	TagsAdded   []string      `json:"tags_added,omitempty"`
+	TagsRemoved []string      `json:"tags_removed,omitempty"`
+}
+
+// SnapshotDiff lists the changes turning one version of a deck into
+// another. A nil To refers to the current content of the deck.
+type SnapshotDiff struct {
+	From           int                  `json:"from"`
+	To             *int                 `json:"to"`
+	OptionsChanged bool                 `json:"options_changed"`
+	Added          []SnapshotCard       `json:"added"`
+	Removed        []SnapshotCard       `json:"removed"`
+	Changed        []SnapshotCardChange `json:"changed"`
+}
+
+// DiffSnapshots compares the content of two versions of a deck. Cards are
+// matched by ID.
+func DiffSnapshots(from, to *SnapshotContent) SnapshotDiff {
+	d := SnapshotDiff{
+		Added:   []SnapshotCard{},
+		Removed: []SnapshotCard{},
+		Changed: []SnapshotCardChange{},
+	}
+
+	fromOptions, _ := json.Marshal(from.Options)
+	toOptions, _ := json.Marshal(to.Options)
+	d.OptionsChanged = string(fromOptions) != string(toOptions) ||
+		!equalIntPointers(from.PresetID, to.PresetID)
+
+	previous := make(map[int]SnapshotCard, len(from.Cards))
+	for _, card := range from.Cards {
+		previous[card.ID] = card
+	}
+
+	for _, card := range to.Cards {
+		old, ok := previous[card.ID]
+		if !ok {
+			d.Added = append(d.Added, card)
+			continue
+		}
+		delete(previous, card.ID)
+
+		change := SnapshotCardChange{CardID: card.ID}
+		changed := false
+		if old.Front != card.Front {
+			change.FrontDiff = diff.Words(old.Front, card.Front)
+			changed = true
+		}
+		if old.Back != card.Back {
+			change.BackDiff = diff.Words(old.Back, card.Back)
+			changed = true
+		}
+		if old.Format != card.Format {
+			change.FormatFrom, change.FormatTo = old.Format, card.Format
+			changed = true
+		}
+		for _, tag := range card.Tags {
+			if !slices.Contains(old.Tags, tag) {
+				change.TagsAdded = append(change.TagsAdded, tag)
+				changed = true
+			}
+		}
+		for _, tag := range old.Tags {
+			if !slices.Contains(card.Tags, tag) {
+				change.TagsRemoved = append(change.TagsRemoved, tag)
+				changed = true
+			}
+		}
+		if changed {
+			d.Changed = append(d.Changed, change)
+		}
+	}
+
+	for _, card := range from.Cards {
+		if _, ok := previous[card.ID]; ok {
+			d.Removed = append(d.Removed, card)
+		}
+	}
+
+	return d
+}
+
+func equalIntPointers(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// Value stores the content as JSONB, see DeckOptions.Value.
+func (c SnapshotContent) Value() (driver.Value, error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (c *SnapshotContent) Scan(src any) error {
+	*c = SnapshotContent{}
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, c)
+	case string:
+		return json.Unmarshal([]byte(v), c)
+	}
+	return fmt.Errorf("cannot scan %T into SnapshotContent", src)
+}
+
+// SnapshotRestore reports what rolling a deck back to a snapshot changed.
+type SnapshotRestore struct {
+	Version  int `json:"version"`
+	Updated  int `json:"updated"`
+	Restored int `json:"restored"`
+	Created  int `json:"created"`
+	Trashed  int `json:"trashed"`
+}
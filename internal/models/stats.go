@@ -0,0 +1,50 @@
+package models
+
+// MatureInterval is the interval from which a card counts as mature: the
+// longest the scheduler gives, to cards answered easy. Cards it gives the
+// regular interval of a day are young.
+const MatureInterval = EasyInterval
+
+// CardStateCounts splits the cards of a deck by their study state, derived
+// from the latest review of each card by the user. Learning cards were last
+// failed or are still going through their learning steps, due again within
+// GoodInterval. Cards the user suspended only count as suspended.
+type CardStateCounts struct {
+	New       int `json:"new" db:"new"`
+	Learning  int `json:"learning" db:"learning"`
+	Young     int `json:"young" db:"young"`
+	Mature    int `json:"mature" db:"mature"`
+	Suspended int `json:"suspended" db:"suspended"`
+}
+
+// RetentionWindow is the share of reviews rated 3 or better in the last
+// Days days. Retention is nil when there were no reviews in the window.
+type RetentionWindow struct {
+	Days      int      `json:"days" db:"days"`
+	Reviews   int      `json:"reviews" db:"reviews"`
+	Passed    int      `json:"passed" db:"passed"`
+	Retention *float64 `json:"retention"`
+}
+
+// DailyReviews counts the reviews of a day. StudyTimeMs sums the durations
+// the reviews reported, reviews without one count as no time.
+type DailyReviews struct {
+	Date        string `json:"date" db:"date"`
+	Reviews     int    `json:"reviews" db:"reviews"`
+	StudyTimeMs int64  `json:"study_time_ms" db:"study_time_ms"`
+}
+
+// DeckStats summarizes the cards and reviews of a deck and its subdecks. The
+// scheduler keeps no ease factor or memory stability, so neither is
+// reported; AverageQuality is the mean rating of the reviews.
+type DeckStats struct {
+	DeckID              int               `json:"deck_id"`
+	TotalCards          int               `json:"total_cards" db:"total_cards"`
+	States              CardStateCounts   `json:"states"`
+	TotalReviews        int               `json:"total_reviews" db:"total_reviews"`
+	AverageQuality      *float64          `json:"average_quality" db:"average_quality"`
+	StudyTimeMs         int64             `json:"study_time_ms" db:"study_time_ms"`
+	AverageIntervalDays *float64          `json:"average_interval_days" db:"average_interval_days"`
+	Retention           []RetentionWindow `json:"retention"`
+	ReviewsPerDay       []DailyReviews    `json:"reviews_per_day"`
+}
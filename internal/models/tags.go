@@ -0,0 +1,42 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// Tag is a tag name with the number of cards the user can view that carry
+// it. Tag names are shared between users, so nothing else about the tag is
+// shown.
+type Tag struct {
+	Name      string `json:"name" db:"name"`
+	CardCount int    `json:"card_count" db:"card_count"`
+}
+
+// NormalizeTags lowercases and trims tag names, drops duplicates and
+// rejects names that cannot be used in tag expressions.
+func NormalizeTags(names []string) ([]string, error) {
+	tags := []string{}
+	seen := make(map[string]bool)
+
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			return nil, errors.New("tag cannot be empty")
+		}
+		if len(name) > 64 {
+			return nil, errors.New("tag cannot be longer than 64 characters")
+		}
+		if strings.ContainsFunc(name, func(r rune) bool {
+			return unicode.IsSpace(r) || r == '(' || r == ')'
+		}) {
+			return nil, errors.New("tag cannot contain whitespace or parentheses")
+		}
+		if !seen[name] {
+			seen[name] = true
+			tags = append(tags, name)
+		}
+	}
+	return tags, nil
+}
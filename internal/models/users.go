@@ -0,0 +1,50 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+type User struct {
+	ID    int    `json:"id" db:"id"`
+	Email string `json:"email" db:"email"`
+	Name  string `json:"name" db:"name"`
+	// PasswordHash is only loaded to check a login and never serialized
+	PasswordHash string    `json:"-" db:"password_hash"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// OIDCIdentity is the identity an OpenID Connect provider asserts for a
+// user, who is identified by the issuer and subject.
+type OIDCIdentity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Normalize trims the name and email and lowercases the email, which
+// identifies the user.
+func (u *User) Normalize() {
+	u.Email = strings.ToLower(strings.TrimSpace(u.Email))
+	u.Name = strings.TrimSpace(u.Name)
+}
+
+func (u *User) Validate() error {
+	if u.Email == "" {
+		return errors.New("email cannot be empty")
+	}
+	if len(u.Email) > 255 {
+		return errors.New("email cannot be longer than 255 characters")
+	}
+	if at := strings.Index(u.Email, "@"); at <= 0 || at == len(u.Email)-1 {
+		return errors.New("email is invalid")
+	}
+	if len(u.Name) > 255 {
+		return errors.New("name cannot be longer than 255 characters")
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+var ErrNotFound = errors.New("file not found")
+
+// Storage keeps media files addressed by the SHA-256 checksum of their content.
+type Storage interface {
+	// Put stores the content read from r and returns its checksum and size.
+	Put(r io.Reader) (checksum string, size int64, err error)
+	Open(checksum string) (io.ReadSeekCloser, error)
+	Delete(checksum string) error
+}
+
+type LocalStorage struct {
+	dir string
+}
+
+func NewLocalStorage(dir string) (*LocalStorage, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	return &LocalStorage{dir: dir}, nil
+}
+
+func (s *LocalStorage) Put(r io.Reader) (string, int64, error) {
+	tmp, err := os.CreateTemp(s.dir, "upload-*")
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), r)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", 0, fmt.Errorf("failed to write file: %w", err)
+	}
+
+	checksum := hex.EncodeToString(hash.Sum(nil))
+	path := s.path(checksum)
+
+	// Same checksum means same content, nothing to do
+	if _, err := os.Stat(path); err == nil {
+		return checksum, size, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", 0, fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", 0, fmt.Errorf("failed to move file: %w", err)
+	}
+
+	return checksum, size, nil
+}
+
+func (s *LocalStorage) Open(checksum string) (io.ReadSeekCloser, error) {
+	f, err := os.Open(s.path(checksum))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	return f, nil
+}
+
+func (s *LocalStorage) Delete(checksum string) error {
+	err := os.Remove(s.path(checksum))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	return nil
+}
+
+// path shards files by the first two characters of the checksum to keep
+// directories small.
+func (s *LocalStorage) path(checksum string) string {
+	return filepath.Join(s.dir, checksum[:2], checksum)
+}
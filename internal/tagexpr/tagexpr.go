@@ -0,0 +1,233 @@
+package tagexpr
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Limits of a tag expression, which bound the recursion of the parser and
+// of the queries built from the expression.
+const (
+	MaxLength = 1000
+	MaxDepth  = 32
+)
+
+// Expr is a parsed tag expression such as "verbs AND NOT irregular".
+type Expr interface {
+	expr()
+}
+
+type Tag struct {
+	Name string
+}
+
+type Not struct {
+	Expr Expr
+}
+
+type And struct {
+	Left, Right Expr
+}
+
+type Or struct {
+	Left, Right Expr
+}
+
+func (Tag) expr() {}
+func (Not) expr() {}
+func (And) expr() {}
+func (Or) expr()  {}
+
+// SyntaxError reports an invalid tag expression. Pos is the position of the
+// offending character, counted in characters from 1.
+type SyntaxError struct {
+	Msg string
+	Pos int
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at position %d in tag expression", e.Msg, e.Pos)
+}
+
+// Parse parses a tag expression. Tags are matched case-insensitively and
+// can be combined with AND, OR, NOT and parentheses. Adjacent tags without
+// an operator are joined with AND, so "verbs regular" equals
+// "verbs AND regular". Expressions are limited to MaxLength characters and
+// MaxDepth nested parentheses and NOT operators.
+func Parse(input string) (Expr, error) {
+	if utf8.RuneCountInString(input) > MaxLength {
+		return nil, &SyntaxError{Msg: fmt.Sprintf("expression longer than %d characters", MaxLength), Pos: MaxLength + 1}
+	}
+
+	tokens, end := tokenize(input)
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty tag expression")
+	}
+
+	p := &parser{tokens: tokens, end: end}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, p.unexpected()
+	}
+	return expr, nil
+}
+
+type token struct {
+	text string
+	pos  int
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	// end is the position just past the last character of the input
+	end int
+	// depth counts the parentheses and NOT operators around the current
+	// token
+	depth int
+}
+
+func (p *parser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos].text
+	}
+	return ""
+}
+
+// enter descends into a parenthesis or NOT operator, failing once the
+// expression is nested deeper than MaxDepth.
+func (p *parser) enter() error {
+	p.depth++
+	if p.depth > MaxDepth {
+		return &SyntaxError{Msg: fmt.Sprintf("expression nested deeper than %d levels", MaxDepth), Pos: p.tokens[p.pos].pos}
+	}
+	return nil
+}
+
+// unexpected reports the current token, or the end of the input.
+func (p *parser) unexpected() error {
+	if p.pos < len(p.tokens) {
+		t := p.tokens[p.pos]
+		return &SyntaxError{Msg: fmt.Sprintf("unexpected %q", t.text), Pos: t.pos}
+	}
+	return &SyntaxError{Msg: "unexpected end", Pos: p.end}
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "OR" {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = Or{Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch p.peek() {
+		case "AND":
+			p.pos++
+		case "", "OR", ")":
+			return left, nil
+		}
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = And{Left: left, Right: right}
+	}
+}
+
+func (p *parser) parseNot() (Expr, error) {
+	if p.peek() == "NOT" {
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+		p.pos++
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		p.depth--
+		return Not{Expr: expr}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	token := p.peek()
+	switch token {
+	case "", ")", "AND", "OR":
+		return nil, p.unexpected()
+	case "(":
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+		open := p.tokens[p.pos].pos
+		p.pos++
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, &SyntaxError{Msg: "missing closing parenthesis", Pos: open}
+		}
+		p.pos++
+		p.depth--
+		return expr, nil
+	}
+	p.pos++
+	return Tag{Name: strings.ToLower(token)}, nil
+}
+
+// tokenize splits the input into parentheses, operators and tag names, and
+// returns them with the position just past the end of the input. Operators
+// are only recognized in upper case so that lower case tags named "and",
+// "or" or "not" still work.
+func tokenize(input string) ([]token, int) {
+	var tokens []token
+	var current strings.Builder
+	start, pos := 0, 0
+
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, token{text: current.String(), pos: start})
+			current.Reset()
+		}
+	}
+
+	for _, r := range input {
+		pos++
+		switch {
+		case unicode.IsSpace(r):
+			flush()
+		case r == '(' || r == ')':
+			flush()
+			tokens = append(tokens, token{text: string(r), pos: pos})
+		default:
+			if current.Len() == 0 {
+				start = pos
+			}
+			current.WriteRune(r)
+		}
+	}
+	flush()
+
+	return tokens, pos + 1
+}
@@ -0,0 +1,105 @@
+package tagexpr
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	a, b, c := Tag{Name: "a"}, Tag{Name: "b"}, Tag{Name: "c"}
+
+	tests := []struct {
+		name  string
+		input string
+		want  Expr
+	}{
+		{"single tag", "verbs", Tag{Name: "verbs"}},
+		{"lowercases tags", "Verbs", Tag{Name: "verbs"}},
+		{"and", "a AND b", And{Left: a, Right: b}},
+		{"implicit and", "a b", And{Left: a, Right: b}},
+		{"or", "a OR b", Or{Left: a, Right: b}},
+		{"not", "NOT a", Not{Expr: a}},
+		{"double not", "NOT NOT a", Not{Expr: Not{Expr: a}}},
+		{"and binds tighter than or", "a OR b AND c", Or{Left: a, Right: And{Left: b, Right: c}}},
+		{"and binds tighter than or on the left", "a AND b OR c", Or{Left: And{Left: a, Right: b}, Right: c}},
+		{"not binds tighter than and", "NOT a AND b", And{Left: Not{Expr: a}, Right: b}},
+		{"parentheses override precedence", "(a OR b) AND c", And{Left: Or{Left: a, Right: b}, Right: c}},
+		{"not applies to parentheses", "NOT (a OR b)", Not{Expr: Or{Left: a, Right: b}}},
+		{"and is left associative", "a AND b AND c", And{Left: And{Left: a, Right: b}, Right: c}},
+		{"or is left associative", "a OR b OR c", Or{Left: Or{Left: a, Right: b}, Right: c}},
+		{"lower case operators are tags", "a and b", And{Left: And{Left: a, Right: Tag{Name: "and"}}, Right: b}},
+		{"parentheses without spaces", "(a)AND(b)", And{Left: a, Right: b}},
+		{"extra whitespace", "  a \t OR\nb  ", Or{Left: a, Right: b}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.input, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %#v, want %#v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		msg   string
+		pos   int
+	}{
+		{"leading operator", "AND a", `unexpected "AND"`, 1},
+		{"trailing operator", "a OR", "unexpected end", 5},
+		{"trailing not", "a AND NOT", "unexpected end", 10},
+		{"double operator", "a AND OR b", `unexpected "OR"`, 7},
+		{"stray closing parenthesis", "a ) b", `unexpected ")"`, 3},
+		{"empty parentheses", "a AND ()", `unexpected ")"`, 8},
+		{"missing closing parenthesis", "a AND (b OR c", "missing closing parenthesis", 7},
+		{"counts characters not bytes", "été AND OR", `unexpected "OR"`, 9},
+		{"too deeply nested", strings.Repeat("(", MaxDepth+1) + "a" + strings.Repeat(")", MaxDepth+1), "expression nested deeper than 32 levels", MaxDepth + 1},
+		{"too many nots", strings.Repeat("NOT ", MaxDepth+1) + "a", "expression nested deeper than 32 levels", 4*MaxDepth + 1},
+		{"unclosed parentheses", strings.Repeat("(", 500), "expression nested deeper than 32 levels", MaxDepth + 1},
+		{"too long", strings.Repeat("a ", MaxLength/2) + "b", "expression longer than 1000 characters", MaxLength + 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("Parse(%q) error = %v, want a SyntaxError", tt.input, err)
+			}
+			if syntaxErr.Msg != tt.msg || syntaxErr.Pos != tt.pos {
+				t.Errorf("Parse(%q) error = %q at %d, want %q at %d", tt.input, syntaxErr.Msg, syntaxErr.Pos, tt.msg, tt.pos)
+			}
+		})
+	}
+}
+
+func TestParseEmpty(t *testing.T) {
+	for _, input := range []string{"", "   "} {
+		if _, err := Parse(input); err == nil {
+			t.Errorf("Parse(%q) expected an error", input)
+		}
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	inputs := []string{
+		strings.Repeat("(", MaxDepth) + "a" + strings.Repeat(")", MaxDepth),
+		strings.Repeat("NOT ", MaxDepth) + "a",
+		strings.Repeat("(a) AND ", 100) + "a",
+		strings.Repeat("x", MaxLength),
+	}
+	for _, input := range inputs {
+		if _, err := Parse(input); err != nil {
+			t.Errorf("Parse() of %d characters error = %v", len(input), err)
+		}
+	}
+}
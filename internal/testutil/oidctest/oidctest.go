@@ -0,0 +1,224 @@
+// Package oidctest runs an OpenID Connect provider in tests of the login
+// flow. It publishes its discovery document and signing keys, hands out an
+// authorization code for each login and redeems it for a signed ID token
+// once the PKCE verifier matches.
+package oidctest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+const keyID = "oidctest"
+
+// Identity is the user the issuer asserts for a login.
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Issuer is an OpenID Connect provider listening on a local HTTP server.
+type Issuer struct {
+	URL      string
+	ClientID string
+
+	// Audience replaces the client ID as the audience of the ID tokens
+	// when it is set.
+	Audience string
+	// SigningKey signs the ID tokens. It is the key published by the
+	// issuer unless a test replaces it to forge a signature.
+	SigningKey *rsa.PrivateKey
+
+	key    *rsa.PrivateKey
+	client *http.Client
+
+	mu     sync.Mutex
+	grants map[string]grant
+}
+
+type grant struct {
+	challenge string
+	nonce     string
+	identity  Identity
+}
+
+// NewIssuer starts an issuer for the client clientID, which is stopped when
+// the test ends.
+func NewIssuer(t testing.TB, clientID string) *Issuer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate signing key: %v", err)
+	}
+
+	issuer := &Issuer{ClientID: clientID, SigningKey: key, key: key, grants: map[string]grant{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", issuer.discovery)
+	mux.HandleFunc("GET /keys", issuer.keys)
+	mux.HandleFunc("POST /token", issuer.token)
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	issuer.URL = server.URL
+	issuer.client = server.Client()
+	return issuer
+}
+
+// Client returns the HTTP client to reach the issuer with.
+func (i *Issuer) Client() *http.Client {
+	return i.client
+}
+
+// Authorize logs identity in on the login page at authURL, as the provider
+// would after the user signed in, and returns the authorization code sent
+// back to the client.
+func (i *Issuer) Authorize(authURL string, identity Identity) (string, error) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+
+	switch {
+	case u.Path != "/authorize":
+		return "", errors.New("not the authorization endpoint")
+	case query.Get("client_id") != i.ClientID:
+		return "", errors.New("unknown client")
+	case query.Get("response_type") != "code":
+		return "", errors.New("unsupported response type")
+	case query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "":
+		return "", errors.New("missing PKCE challenge")
+	}
+
+	code := rand.Text()
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.grants[code] = grant{
+		challenge: query.Get("code_challenge"),
+		nonce:     query.Get("nonce"),
+		identity:  identity,
+	}
+	return code, nil
+}
+
+func (i *Issuer) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                i.URL,
+		"authorization_endpoint":                i.URL + "/authorize",
+		"token_endpoint":                        i.URL + "/token",
+		"jwks_uri":                              i.URL + "/keys",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (i *Issuer) keys(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": keyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   encode(i.key.N.Bytes()),
+			"e":   encode(big.NewInt(int64(i.key.E)).Bytes()),
+		}},
+	})
+}
+
+// token redeems an authorization code once, if the verifier hashes to the
+// challenge of its login.
+func (i *Issuer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	i.mu.Lock()
+	code := r.PostForm.Get("code")
+	g, ok := i.grants[code]
+	delete(i.grants, code)
+	i.mu.Unlock()
+
+	hash := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || encode(hash[:]) != g.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	idToken, err := i.idToken(g)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": rand.Text(),
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+func (i *Issuer) idToken(g grant) (string, error) {
+	audience := i.ClientID
+	if i.Audience != "" {
+		audience = i.Audience
+	}
+
+	now := time.Now()
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "kid": keyID, "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"iss":            i.URL,
+		"sub":            g.identity.Subject,
+		"aud":            audience,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+		"nonce":          g.nonce,
+		"email":          g.identity.Email,
+		"email_verified": g.identity.EmailVerified,
+		"name":           g.identity.Name,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := encode(header) + "." + encode(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, i.SigningKey, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + encode(signature), nil
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}